@@ -1,52 +1,259 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"mtg-bulk-input/internal/data"
+	"mtg-bulk-input/internal/deckbox"
+	"mtg-bulk-input/internal/export"
+	"mtg-bulk-input/internal/importer"
+	"mtg-bulk-input/internal/query"
+	"mtg-bulk-input/internal/scryfall"
 	"mtg-bulk-input/internal/ui"
 	"os"
 	"path"
+	"sort"
 	"strings"
+	"text/tabwriter"
+	"time"
 )
 
 const (
 	port = 8080
+
+	progressBarWidth = 40
 )
 
 func main() {
-	if len(os.Args) != 2 {
-		log.Fatalf("USAGE: mtg-bulk-input [path/to/file.json]")
+	quiet := flag.Bool("quiet", false, "don't show download progress")
+	offline := flag.Bool("offline", false, "don't check for new bulk data, use what has already been downloaded")
+	dataDir := flag.String("data-dir", data.DefaultWorkingDirectory(), "directory to store downloaded data in, defaults to $"+data.WorkingDirectoryEnv+" or the user cache directory")
+	format := flag.String("format", deckbox.ExporterName, "default export format, one of: "+strings.Join(exportFormats(), ", "))
+	editionsFile := flag.String("editions", "", "JSON file of Deckbox edition overrides, defaults to "+deckbox.EditionOverrideFileName+" in the data directory")
+	resolve := flag.String("resolve", string(importer.StrategyPrompt), "how imported cards without a collector number pick a printing: prompt, cheapest, newest, oldest or preferred")
+	preferSets := flag.String("prefer-sets", "", "comma separated set codes tried in order by -resolve preferred, e.g. m10,2xm")
+	bulkTypes := flag.String("bulk", "", "comma separated extra bulk data types to load (oracle_cards, unique_artwork, all_cards, rulings)")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "USAGE: mtg-bulk-input [flags] [path/to/file.json]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       mtg-bulk-input [flags] update\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       mtg-bulk-input [flags] search <query>\n")
+		flag.PrintDefaults()
 	}
 
-	if !strings.HasSuffix(path.Base(os.Args[1]), ".json") {
-		log.Fatalf("specified file must be a .json file")
+	flag.Parse()
+
+	search := flag.Arg(0) == "search"
+
+	if flag.NArg() != 1 && !(search && flag.NArg() > 1) {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *bulkTypes != "" {
+		err := data.SetRequiredBulkTypes(strings.Split(*bulkTypes, ","))
+		if err != nil {
+			log.Fatalf("invalid -bulk flag: %v", err)
+		}
+	}
+
+	strategy, err := importer.ParseStrategy(*resolve)
+	if err != nil {
+		log.Fatalf("invalid -resolve flag: %v", err)
 	}
 
-	err := data.SetupDirectories()
+	names := importer.NameResolution{Strategy: strategy}
+
+	if *preferSets != "" {
+		names.PreferredSets = strings.Split(*preferSets, ",")
+	} else if strategy == importer.StrategyPreferred {
+		log.Fatalf("-resolve preferred needs -prefer-sets")
+	}
+
+	data.SetWorkingDirectory(*dataDir)
+
+	err = data.SetupDirectories()
 	if err != nil {
 		log.Fatalf("failed to setup working directories: %v", err)
 	}
 
-	downloaded, err := data.DownloadBulkDataIfNewer()
+	// Checked before any data is downloaded so mistakes are reported straight away. The edition overrides are read
+	// from the data directory by default.
+	editions, err := deckbox.LoadEditionMapper(*editionsFile)
 	if err != nil {
-		log.Fatalf("failed to download bulk files: %v", err)
+		log.Fatalf("failed to load edition mapping: %v", err)
 	}
 
-	if downloaded == 0 {
-		log.Println("no new bulk files to download")
+	export.Register(deckbox.NewExporter(editions))
+
+	exporter, ok := export.Get(*format)
+	if !ok {
+		log.Fatalf("unknown export format '%s', expected one of: %s", *format, strings.Join(export.Names(), ", "))
+	}
+
+	client := scryfall.NewClient()
+
+	var progress scryfall.ProgressFunc
+	if !*quiet {
+		progress = renderProgressBar
+	}
+
+	if flag.Arg(0) == "update" {
+		if *offline {
+			log.Fatalf("cannot update while offline")
+		}
+
+		err = updateBulkData(client, progress)
+		if err != nil {
+			log.Fatalf("failed to download bulk files: %v", err)
+		}
+
+		return
+	}
+
+	var q query.Query
+
+	if search {
+		// Parsed before loading anything so mistakes are reported straight away
+		q, err = query.Parse(strings.Join(flag.Args()[1:], " "))
+		if err != nil {
+			log.Fatalf("invalid query: %v", err)
+		}
+	}
+
+	filepath := flag.Arg(0)
+
+	if !search && !strings.HasSuffix(path.Base(filepath), ".json") {
+		log.Fatalf("specified file must be a .json file")
+	}
+
+	if *offline {
+		if !data.CachedBulkDataExists() {
+			log.Fatalf("bulk data has not been downloaded yet, run without -offline or use 'update' first")
+		}
+
+		log.Println("offline, using previously downloaded bulk data")
 	} else {
-		log.Printf("downloaded %d new bulk files", downloaded)
+		err = updateBulkData(client, progress)
+		if err != nil {
+			if !data.CachedBulkDataExists() {
+				log.Fatalf("failed to download bulk files: %v", err)
+			}
+
+			log.Printf("WARNING: failed to update bulk files, using previously downloaded data: %v", err)
+		}
 	}
 
 	log.Println("building store...")
 
-	store, err := data.BuildDataStore()
+	buildStart := time.Now()
+
+	store, err := data.LoadDataStore()
 	if err != nil {
 		log.Fatalf("failed to build data store: %v", err)
 	}
 
-	err = ui.Start(os.Args[1], store)
+	log.Printf("built store in %s", time.Since(buildStart).Round(time.Millisecond))
+
+	if search {
+		err = printSearch(store, q)
+		if err != nil {
+			log.Fatalf("failed to print search results: %v", err)
+		}
+
+		return
+	}
+
+	err = ui.Start(filepath, store, editions, exporter, names)
 	if err != nil {
 		log.Fatalf("ui errored: %v", err)
 	}
 }
+
+/*
+exportFormats lists every export format for the -format usage, which is shown before the Deckbox exporter is registered
+*/
+func exportFormats() []string {
+	out := append(export.Names(), deckbox.ExporterName)
+
+	sort.Strings(out)
+
+	return out
+}
+
+func updateBulkData(client *scryfall.Client, progress scryfall.ProgressFunc) error {
+	downloaded, err := data.DownloadBulkDataIfNewer(client, progress)
+	if err != nil {
+		return err
+	}
+
+	if downloaded == 0 {
+		log.Println("no new bulk files to download")
+	} else {
+		log.Printf("downloaded %d new bulk files", downloaded)
+	}
+
+	return nil
+}
+
+/*
+printSearch writes the cards matching the query to stdout as a table
+*/
+func printSearch(store data.Store, q query.Query) error {
+	cards := query.Search(store, q)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "Name\tSet\tNumber\tRarity\tType\tUSD")
+
+	for _, c := range cards {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", c.Name, strings.ToUpper(c.Set), c.CollectorNumber, c.Rarity, c.TypeLine, c.Prices.Usd)
+	}
+
+	err := w.Flush()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%d cards found", len(cards))
+
+	return nil
+}
+
+/*
+renderProgressBar redraws a single line progress bar on stderr, moving to a new line when finished
+*/
+func renderProgressBar(p scryfall.Progress) {
+	rate := fmt.Sprintf("%.1f MB/s", p.Rate/1e6)
+
+	var line string
+
+	if p.Total > 0 {
+		fraction := float64(p.Done) / float64(p.Total)
+		if fraction > 1 {
+			fraction = 1
+		}
+
+		filled := int(fraction * progressBarWidth)
+
+		line = fmt.Sprintf("[%s%s] %5.1f%% %.1f/%.1f MB %s ETA %s",
+			strings.Repeat("=", filled),
+			strings.Repeat(" ", progressBarWidth-filled),
+			fraction*100,
+			float64(p.Done)/1e6,
+			float64(p.Total)/1e6,
+			rate,
+			p.ETA.Round(time.Second),
+		)
+	} else {
+		line = fmt.Sprintf("%.1f MB %s", float64(p.Done)/1e6, rate)
+	}
+
+	// Pad to clear anything left over from a longer previous line
+	fmt.Fprintf(os.Stderr, "\r%-100s", line)
+
+	if p.Finished {
+		fmt.Fprintln(os.Stderr)
+	}
+}
@@ -1,6 +1,6 @@
 module mtg-bulk-input
 
-go 1.19
+go 1.21
 
 require (
 	github.com/dghubble/trie v0.0.0-20230729160116-2bc358f28a8b
@@ -3,21 +3,72 @@ package data
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"mtg-bulk-input/internal/scryfall"
 	"os"
 	"path"
+	"slices"
+	"strings"
 )
 
+// How many times an interrupted download (a transport error or 5xx) is resumed before giving up
+const maxDownloadAttempts = 5
+
+const (
+	BulkTypeDefaultCards  = "default_cards"
+	BulkTypeOracleCards   = "oracle_cards"
+	BulkTypeUniqueArtwork = "unique_artwork"
+	BulkTypeAllCards      = "all_cards"
+	BulkTypeRulings       = "rulings"
+)
+
+// Every bulk type that BuildDataStore knows how to load
+var supportedBulkFileTypes = []string{
+	BulkTypeDefaultCards,
+	BulkTypeOracleCards,
+	BulkTypeUniqueArtwork,
+	BulkTypeAllCards,
+	BulkTypeRulings,
+}
+
 var requiredBulkFileTypes = []string{
-	"default_cards",
+	BulkTypeDefaultCards,
 }
 
 /*
-Download the latest card data from the scryfall bulk data API
+SetRequiredBulkTypes configures which bulk files are downloaded and loaded into the store.
+default_cards is always required as the store is built around it.
 */
-func DownloadBulkDataIfNewer() (int, error) {
+func SetRequiredBulkTypes(types []string) error {
+	required := []string{BulkTypeDefaultCards}
+
+	for _, t := range types {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+
+		if !slices.Contains(supportedBulkFileTypes, t) {
+			return fmt.Errorf("unsupported bulk data type '%s', expected one of: %s", t, strings.Join(supportedBulkFileTypes, ", "))
+		}
+
+		if !slices.Contains(required, t) {
+			required = append(required, t)
+		}
+	}
+
+	requiredBulkFileTypes = required
+
+	return nil
+}
+
+/*
+Download the latest card data from the scryfall bulk data API,
+progress is reported for each file downloaded unless it is nil
+*/
+func DownloadBulkDataIfNewer(client *scryfall.Client, progress scryfall.ProgressFunc) (int, error) {
 	// Get our stored metadata for comparison
 	var meta scryfall.BulkDataList
 	metadataExists := true
@@ -32,7 +83,7 @@ func DownloadBulkDataIfNewer() (int, error) {
 	}
 
 	// Get the upstream metadata
-	newMeta, err := scryfall.GetBulkDataMeta()
+	newMeta, err := client.GetBulkDataMeta()
 	if err != nil {
 		return 0, fmt.Errorf("failed to get new bulk metadata: %w", err)
 	}
@@ -42,10 +93,14 @@ func DownloadBulkDataIfNewer() (int, error) {
 
 	// Compare the two for each requested type to populate toDownload list
 	for _, t := range requiredBulkFileTypes {
-		if metadataExists {
+		if !bulkFileExists(t) {
+			toDownload = append(toDownload, t)
+		} else if metadataExists {
 			bdType, ok := meta.GetType(t)
 			if !ok {
-				return 0, fmt.Errorf("existing metadata does not contain type '%s'", t)
+				// Nothing to compare against, so the file on disk can't be trusted
+				toDownload = append(toDownload, t)
+				continue
 			}
 
 			newBdType, ok := newMeta.GetType(t)
@@ -71,22 +126,190 @@ func DownloadBulkDataIfNewer() (int, error) {
 
 		log.Printf("downloading bulk data type '%s' from '%s'", t, bdType.DownloadUri)
 
-		p := path.Join(WorkingDirectory, BulkDataDirectory, fmt.Sprintf("%s.json", t))
+		err := downloadBulkFile(client, bdType, progress)
+		if err != nil {
+			return i, fmt.Errorf("failed to download bulk file '%s': %w", t, err)
+		}
+	}
+
+	// Update the meta file on disk, only once every file is in place. Types that aren't required keep
+	// their old entry as the file on disk (if any) wasn't refreshed.
+	outMeta := scryfall.BulkDataList{
+		Data: make([]scryfall.BulkData, 0, len(newMeta.Data)),
+	}
+
+	for _, bd := range newMeta.Data {
+		if slices.Contains(requiredBulkFileTypes, bd.Type) {
+			outMeta.Data = append(outMeta.Data, bd)
+		} else if oldBd, ok := meta.GetType(bd.Type); ok {
+			outMeta.Data = append(outMeta.Data, oldBd)
+		}
+	}
+
+	err = WriteJsonFile(BulkDataDirectory, "meta.json", outMeta)
+	if err != nil {
+		return len(toDownload), fmt.Errorf("failed to write bulk metadata: %w", err)
+	}
+
+	return len(toDownload), nil
+}
+
+/*
+CachedBulkDataExists reports whether every required bulk file has previously been downloaded,
+in which case the store can be built without contacting scryfall
+*/
+func CachedBulkDataExists() bool {
+	for _, t := range requiredBulkFileTypes {
+		if !bulkFileExists(t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func bulkFileExists(t string) bool {
+	_, err := os.Stat(bulkFilePath(t))
+	return err == nil
+}
+
+func bulkFilePath(t string) string {
+	return path.Join(WorkingDirectory, BulkDataDirectory, fmt.Sprintf("%s.json", t))
+}
+
+/*
+partialDownload records which upstream file a '.part' file belongs to, so a stale partial download
+from an older bulk file is never resumed into a newer one
+*/
+type partialDownload struct {
+	DownloadUri string `json:"download_uri"`
+}
+
+/*
+downloadBulkFile downloads a bulk file into '<type>.json.part', resuming a previous partial download
+of the same file where possible. The size is checked against the metadata and the file is only
+renamed over '<type>.json' once it is complete.
+*/
+func downloadBulkFile(client *scryfall.Client, bd scryfall.BulkData, progress scryfall.ProgressFunc) error {
+	fileName := fmt.Sprintf("%s.json", bd.Type)
+	partName := fileName + ".part"
+	partMetaName := partName + ".meta.json"
 
-		f, err := os.OpenFile(p, os.O_CREATE|os.O_RDWR, 0755)
+	p := path.Join(WorkingDirectory, BulkDataDirectory, fileName)
+	partPath := path.Join(WorkingDirectory, BulkDataDirectory, partName)
+
+	// Discard any partial download that isn't for this exact file
+	var partMeta partialDownload
+	err := ReadJsonFile(BulkDataDirectory, partMetaName, &partMeta)
+	if err != nil || partMeta.DownloadUri != bd.DownloadUri {
+		err = os.Remove(partPath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("unable to remove stale partial download '%s': %w", partPath, err)
+		}
+
+		err = WriteJsonFile(BulkDataDirectory, partMetaName, partialDownload{DownloadUri: bd.DownloadUri})
 		if err != nil {
-			return i, fmt.Errorf("unable to open file at '%s': %w", p, err)
+			return fmt.Errorf("unable to record partial download: %w", err)
 		}
+	}
 
-		err = scryfall.DownloadBulkFile(bdType.DownloadUri, f)
-		f.Close()
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("unable to open file at '%s': %w", partPath, err)
+	}
+	defer f.Close()
+
+	var pw *scryfall.ProgressWriter
+
+	for attempt := 0; ; attempt++ {
+		offset, err := f.Seek(0, io.SeekEnd)
 		if err != nil {
-			return i, fmt.Errorf("failed to download bulk file '%s': %w", t, err)
+			return fmt.Errorf("unable to seek partial download '%s': %w", partPath, err)
+		}
+
+		if bd.Size > 0 && offset >= int64(bd.Size) {
+			// Already complete, or larger than expected in which case the size check fails below
+			break
+		}
+
+		if offset > 0 {
+			log.Printf("resuming download of '%s' from byte %d", bd.Type, offset)
 		}
+
+		pw = scryfall.NewProgressWriter(f, offset, int64(bd.Size), progress)
+
+		err = client.DownloadBulkFile(bd.DownloadUri, pw, offset)
+		if errors.Is(err, scryfall.ErrRangeIgnored) {
+			log.Printf("server would not resume download of '%s', starting again", bd.Type)
+
+			if err = truncate(f); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err == nil {
+			break
+		}
+
+		if !scryfall.Retryable(err) {
+			return err
+		}
+
+		if attempt >= maxDownloadAttempts-1 {
+			return fmt.Errorf("download did not complete after %d attempts: %w", maxDownloadAttempts, err)
+		}
+
+		log.Printf("download of '%s' interrupted, will resume: %v", bd.Type, err)
 	}
 
-	// Update the meta file on disk
-	err = WriteJsonFile(BulkDataDirectory, "meta.json", newMeta)
+	if pw != nil {
+		pw.Finish()
+	}
 
-	return len(toDownload), nil
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("unable to stat downloaded file '%s': %w", partPath, err)
+	}
+
+	if bd.Size > 0 && info.Size() != int64(bd.Size) {
+		// Don't keep a file we know is bad, the next run will start from scratch
+		f.Close()
+		os.Remove(partPath)
+		return fmt.Errorf("downloaded file is %d bytes but metadata says %d", info.Size(), bd.Size)
+	}
+
+	err = f.Sync()
+	if err != nil {
+		return fmt.Errorf("unable to sync downloaded file '%s': %w", partPath, err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("unable to close downloaded file '%s': %w", partPath, err)
+	}
+
+	err = os.Rename(partPath, p)
+	if err != nil {
+		return fmt.Errorf("unable to move downloaded file into place at '%s': %w", p, err)
+	}
+
+	_ = os.Remove(path.Join(WorkingDirectory, BulkDataDirectory, partMetaName))
+
+	return nil
+}
+
+func truncate(f *os.File) error {
+	err := f.Truncate(0)
+	if err != nil {
+		return fmt.Errorf("unable to truncate '%s': %w", f.Name(), err)
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("unable to seek '%s': %w", f.Name(), err)
+	}
+
+	return nil
 }
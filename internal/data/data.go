@@ -1,19 +1,53 @@
 package data
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"path/filepath"
 )
 
 const (
-	WorkingDirectory = "./data"
+	// Environment variable that overrides the default working directory
+	WorkingDirectoryEnv = "DECKBOX_CSV_DATA_DIR"
 
 	// Where the bulk data files, and metadata are stored
 	BulkDataDirectory = "bulk"
 )
 
+/*
+WorkingDirectory is the root of everything stored by the data package,
+see DefaultWorkingDirectory and SetWorkingDirectory
+*/
+var WorkingDirectory = DefaultWorkingDirectory()
+
+/*
+DefaultWorkingDirectory is $DECKBOX_CSV_DATA_DIR if set, otherwise a directory in the user's cache directory
+(e.g. $XDG_CACHE_HOME/deckbox-csv-generator). It falls back to ./data if there is no cache directory.
+*/
+func DefaultWorkingDirectory() string {
+	if dir := os.Getenv(WorkingDirectoryEnv); dir != "" {
+		return dir
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "./data"
+	}
+
+	return path.Join(cacheDir, "deckbox-csv-generator")
+}
+
+/*
+SetWorkingDirectory changes where data is read from and written to, it must be called before SetupDirectories
+*/
+func SetWorkingDirectory(dir string) {
+	WorkingDirectory = dir
+}
+
 func SetupDirectories() error {
 	directoriesToSetup := []string{
 		path.Join(WorkingDirectory, BulkDataDirectory),
@@ -36,6 +70,7 @@ func ReadJsonFile(dir string, file string, out any) error {
 	if err != nil {
 		return fmt.Errorf("failed to open file '%s': %w", p, err)
 	}
+	defer f.Close()
 
 	dec := json.NewDecoder(f)
 
@@ -47,20 +82,61 @@ func ReadJsonFile(dir string, file string, out any) error {
 	return nil
 }
 
+/*
+WriteJsonFile encodes out to a temporary file alongside the target and renames it into place,
+so readers never see a partially written file
+*/
 func WriteJsonFile(dir string, file string, out any) error {
 	p := path.Join(WorkingDirectory, dir, file)
 
-	f, err := os.OpenFile(p, os.O_CREATE|os.O_RDWR, 0755)
+	return WriteFileAtomic(p, 0640, func(w io.Writer) error {
+		err := json.NewEncoder(w).Encode(out)
+		if err != nil {
+			return fmt.Errorf("unable to write json file '%s': %w", p, err)
+		}
+
+		return nil
+	})
+}
+
+/*
+WriteFileAtomic calls write with a buffered temporary file alongside p, which is given perm and renamed over p
+once write has succeeded. Readers never see a partially written file and a failed write leaves p as it was.
+Errors from write are returned as is.
+*/
+func WriteFileAtomic(p string, perm os.FileMode, write func(w io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(p), filepath.Base(p)+".*.tmp")
 	if err != nil {
-		return fmt.Errorf("unable to open file at '%s': %w", p, err)
+		return fmt.Errorf("unable to create temporary file for '%s': %w", p, err)
 	}
-	defer f.Close()
+	defer os.Remove(f.Name()) // No-op once renamed
+
+	w := bufio.NewWriter(f)
 
-	enc := json.NewEncoder(f)
+	err = write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	// CreateTemp makes the file owner only
+	err = f.Chmod(perm)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("unable to set permissions of '%s': %w", p, err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("unable to close '%s': %w", p, err)
+	}
 
-	err = enc.Encode(out)
+	err = os.Rename(f.Name(), p)
 	if err != nil {
-		return fmt.Errorf("unable to write json file '%s': %w", p, err)
+		return fmt.Errorf("unable to move '%s' into place: %w", p, err)
 	}
 
 	return nil
@@ -4,74 +4,527 @@ import (
 	"mtg-bulk-input/internal/scryfall"
 	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 /*
 	CardIndex is a fast data structure for getting cards by their names.
-	It lower-cases the string values and strips any punctuation marks to make searching faster.
-	It works by sorting together all names by the first three characters.
+	Names are folded (lower-cased, accents and punctuation removed, see FoldName) so "lim-dul" finds "Lim-Dûl's Vault".
+	Searches match the whole name, its start, the start of each word, anywhere in the name and finally words with typos,
+	scoring each match so the best come first.
+	Every card is indexed, those usually left out of searches (tokens, digital cards...) are filtered by their Exclusions.
 */
 
 type CardIndex struct {
-	prefixIndex map[string][]scryfall.Card
+	// Keyed: folded name
+	names map[string]*indexedName
+
+	// Keyed: folded front face name -> folded full name, for cards with several faces e.g. "delver of secrets"
+	faces map[string][]string
+
+	// Every indexedName in names, sorted by Sort
+	order []*indexedName
+
+	// Keyed: name as printed, including front face names -> folded name, so searches needn't fold every card again
+	folded map[string]string
+}
+
+type indexedName struct {
+	folded string
+	words  []string
+
+	// The folded name without spaces, so "limdul" finds "lim dul"
+	compact string
+
+	cards []indexedCard
+}
+
+type indexedCard struct {
+	card       scryfall.Card
+	exclusions Exclusions
 }
 
-func NewCardIndex() CardIndex {
-	return CardIndex{
-		prefixIndex: make(map[string][]scryfall.Card),
+/*
+Exclusions are the reasons a card is left out of searches unless asked for, as a set of flags
+*/
+type Exclusions uint8
+
+const (
+	// Not legal in any format for a reason not listed below, e.g. Un-set cards, oversized cards and many promos
+	ExcludedNotLegal Exclusions = 1 << iota
+	ExcludedToken
+	ExcludedArtSeries
+
+	// Digital only and not legal in any format, those legal in e.g. Alchemy or Historic aren't excluded
+	ExcludedDigital
+)
+
+// Searching with IncludeNone leaves out every card with an exclusion
+const IncludeNone Exclusions = 0
+
+var exclusionNames = []struct {
+	e    Exclusions
+	name string
+}{
+	{ExcludedNotLegal, "not legal"},
+	{ExcludedToken, "token"},
+	{ExcludedArtSeries, "art series"},
+	{ExcludedDigital, "digital"},
+}
+
+func (e Exclusions) String() string {
+	names := make([]string, 0)
+
+	for _, en := range exclusionNames {
+		if e&en.e != 0 {
+			names = append(names, en.name)
+		}
 	}
+
+	return strings.Join(names, ", ")
 }
 
-func (ci CardIndex) Add(card scryfall.Card) {
-	if !isLegal(card) {
-		return
+/*
+Allows reports whether a card with the exclusions is let through when searching with include, the exclusions asked for
+*/
+func (e Exclusions) Allows(include Exclusions) bool {
+	return e&^include == 0
+}
+
+/*
+CardExclusions works out why a card would be left out of searches. Cards only count as not legal if there's no more
+specific reason, so a token is only excluded as a token. Digital cards are only excluded when they aren't legal,
+as the paper cards are.
+*/
+func CardExclusions(card scryfall.Card) Exclusions {
+	var out Exclusions
+
+	switch {
+	case card.Layout == "token" || card.Layout == "double_faced_token" || card.Layout == "emblem" || card.SetType == "token":
+		out |= ExcludedToken
+	case card.Layout == "art_series":
+		out |= ExcludedArtSeries
+	case isLegal(card):
+	case card.Digital:
+		out |= ExcludedDigital
+	default:
+		out |= ExcludedNotLegal
 	}
 
-	key := strings.ToLower(card.Name)
+	return out
+}
+
+/*
+NameMatch is a card name found by Search, Score is higher for better matches
+*/
+type NameMatch struct {
+	Name  string
+	Score int
+
+	// Every printing of the card
+	Cards []scryfall.Card
+}
+
+const (
+	scoreExact        = 100
+	scorePrefix       = 90
+	scoreWordPrefix   = 80
+	scoreSubstring    = 70
+	scoreCompact      = 65
+	scoreFuzzy        = 50
+	scorePerTypo      = 10
+	scorePerExtraWord = 1
+
+	// The most names returned by Search, the best matches are kept
+	searchLimit = 100
 
-	var prefix string
+	// Shorter queries only match whole names and the start of names, anything else matches too much to be useful
+	minPartialQueryLength = 3
+)
+
+// Letters folded to plain ASCII, covering those used in card names
+var foldedRunes = map[rune]string{
+	'á': "a", 'à': "a", 'â': "a", 'ä': "a", 'ã': "a", 'å': "a",
+	'é': "e", 'è': "e", 'ê': "e", 'ë': "e",
+	'í': "i", 'ì': "i", 'î': "i", 'ï': "i",
+	'ó': "o", 'ò': "o", 'ô': "o", 'ö': "o", 'õ': "o", 'ø': "o",
+	'ú': "u", 'ù': "u", 'û': "u", 'ü': "u",
+	'ñ': "n", 'ç': "c", 'ý': "y",
+	'æ': "ae", 'œ': "oe", 'ß': "ss",
+}
 
-	if len(key) > 3 {
-		prefix = key[:3]
-	} else {
-		prefix = key
+/*
+FoldName normalises a card name for searching: lower case, accents removed ("Jötun" is "jotun"), apostrophes
+dropped ("Urza's" is "urzas") and any other punctuation treated as a space between words ("Lim-Dûl" is "lim dul").
+*/
+func FoldName(name string) string {
+	var sb strings.Builder
+	sb.Grow(len(name))
+
+	space := true // Drops leading spaces
+
+	for _, r := range strings.ToLower(name) {
+		if f, ok := foldedRunes[r]; ok {
+			sb.WriteString(f)
+			space = false
+			continue
+		}
+
+		switch {
+		case r == '\'' || r == '’':
+			// Apostrophes join the word
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			sb.WriteRune(r)
+			space = false
+		case !space:
+			sb.WriteByte(' ')
+			space = true
+		}
 	}
 
-	sharedPrefix, ok := ci.prefixIndex[prefix]
+	return strings.TrimRight(sb.String(), " ")
+}
+
+func NewCardIndex() *CardIndex {
+	return &CardIndex{
+		names:  make(map[string]*indexedName),
+		faces:  make(map[string][]string),
+		folded: make(map[string]string),
+	}
+}
+
+func (ci *CardIndex) Add(card scryfall.Card) {
+	key := FoldName(card.Name)
+
+	entry, ok := ci.names[key]
 	if !ok {
-		sharedPrefix = make([]scryfall.Card, 0)
+		entry = &indexedName{
+			folded:  key,
+			words:   strings.Fields(key),
+			compact: strings.ReplaceAll(key, " ", ""),
+		}
+
+		ci.names[key] = entry
+		ci.order = append(ci.order, entry)
+
+		if front, _, found := strings.Cut(card.Name, " // "); found {
+			frontKey := FoldName(front)
+			ci.faces[frontKey] = append(ci.faces[frontKey], key)
+		}
+	}
+
+	if _, ok := ci.folded[card.Name]; !ok {
+		ci.folded[card.Name] = key
+
+		if front, _, found := strings.Cut(card.Name, " // "); found {
+			ci.folded[front] = FoldName(front)
+		}
+	}
+
+	entry.cards = append(entry.cards, indexedCard{card: card, exclusions: CardExclusions(card)})
+}
+
+/*
+Sort orders the names alphabetically and each name's printings newest first, call it once every card has been added
+*/
+func (ci *CardIndex) Sort() {
+	sort.Slice(ci.order, func(i, j int) bool {
+		return ci.order[i].folded < ci.order[j].folded
+	})
+
+	for _, entry := range ci.order {
+		cards := entry.cards
+		sort.SliceStable(cards, func(i, j int) bool {
+			if cards[i].card.ReleasedAt != cards[j].card.ReleasedAt {
+				return cards[i].card.ReleasedAt > cards[j].card.ReleasedAt
+			}
+
+			if cards[i].card.Set != cards[j].card.Set {
+				return cards[i].card.Set < cards[j].card.Set
+			}
+
+			// Snapshots are indexed from a map, so the order must not depend on the order cards were added in
+			return cards[i].card.CollectorNumber < cards[j].card.CollectorNumber
+		})
+	}
+}
+
+/*
+Search returns the printings of the cards best matching term, best match first. Cards are left out if they have
+exclusions not in include.
+*/
+func (ci *CardIndex) Search(term string, include Exclusions) []scryfall.Card {
+	out := make([]scryfall.Card, 0)
+
+	for _, m := range ci.SearchNames(term, include) {
+		out = append(out, m.Cards...)
 	}
 
-	sharedPrefix = append(sharedPrefix, card)
-	ci.prefixIndex[prefix] = sharedPrefix
+	return out
 }
 
-func (ci CardIndex) Sort() {
-	for _, v := range ci.prefixIndex {
-		v := v
-		sort.Slice(v, func(i, j int) bool {
-			return strings.Compare(v[i].Name, v[j].Name) == -1
+/*
+SearchNames scores every card name against term, returning up to searchLimit matches, best first.
+Ties go to the shorter name, then alphabetically.
+*/
+func (ci *CardIndex) SearchNames(term string, include Exclusions) []NameMatch {
+	query := FoldName(term)
+	if query == "" {
+		return []NameMatch{}
+	}
+
+	queryWords := strings.Fields(query)
+	queryCompact := strings.ReplaceAll(query, " ", "")
+	partial := utf8.RuneCountInString(query) >= minPartialQueryLength
+
+	out := make([]NameMatch, 0)
+
+	var rows distanceRows
+
+	for _, entry := range ci.order {
+		score := entry.score(query, queryWords, queryCompact, partial, &rows)
+		if score <= 0 {
+			continue
+		}
+
+		cards := entry.allowed(include)
+		if len(cards) == 0 {
+			continue
+		}
+
+		out = append(out, NameMatch{
+			Name:  cards[0].Name,
+			Score: score,
+			Cards: cards,
 		})
 	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+
+		return len(out[i].Name) < len(out[j].Name)
+	})
+
+	if len(out) > searchLimit {
+		out = out[:searchLimit]
+	}
+
+	return out
 }
 
-func (ci CardIndex) Search(term string) []scryfall.Card {
-	if len(term) < 3 {
-		return []scryfall.Card{}
+/*
+allowed returns the printings without exclusions outside of include
+*/
+func (in *indexedName) allowed(include Exclusions) []scryfall.Card {
+	out := make([]scryfall.Card, 0, len(in.cards))
+
+	for _, ic := range in.cards {
+		if ic.exclusions.Allows(include) {
+			out = append(out, ic.card)
+		}
 	}
 
-	key := strings.ToLower(term)
+	return out
+}
+
+/*
+score rates how well the name matches the folded query, 0 is no match. Unless partial is set only the whole name and
+its start are matched.
+*/
+func (in *indexedName) score(query string, queryWords []string, queryCompact string, partial bool, rows *distanceRows) int {
+	// Names with more words than the query are slightly worse matches
+	extra := (len(in.words) - len(queryWords)) * scorePerExtraWord
+	if extra < 0 {
+		extra = 0
+	}
 
-	sharedPrefix, ok := ci.prefixIndex[key[:3]]
+	switch {
+	case in.folded == query:
+		return scoreExact
+	case strings.HasPrefix(in.folded, query):
+		return scorePrefix - extra
+	case !partial:
+		return 0
+	case wordsHavePrefixes(in.words, queryWords):
+		return scoreWordPrefix - extra
+	case strings.Contains(in.folded, query):
+		return scoreSubstring - extra
+	case strings.Contains(in.compact, queryCompact):
+		return scoreCompact - extra
+	}
+
+	typos, ok := fuzzyMatch(in.words, queryWords, rows)
 	if !ok {
-		return []scryfall.Card{}
+		return 0
 	}
 
+	return scoreFuzzy - typos*scorePerTypo - extra
+}
+
+/*
+wordsHavePrefixes reports whether every query word starts a different word of the name, e.g. "bolt" or "li bo" for
+"lightning bolt"
+*/
+func wordsHavePrefixes(words []string, queryWords []string) bool {
+	used := make([]bool, len(words))
+
+	for _, qw := range queryWords {
+		found := false
+
+		for i, w := range words {
+			if !used[i] && strings.HasPrefix(w, qw) {
+				used[i] = true
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+fuzzyMatch matches every query word to the start of a word in the name allowing for typos, returning the total number
+of typos. Short words have to be typed exactly as there are too many near misses.
+*/
+func fuzzyMatch(words []string, queryWords []string, rows *distanceRows) (int, bool) {
+	total := 0
+
+	for _, qw := range queryWords {
+		allowed := allowedTypos(qw)
+
+		best := allowed + 1
+
+		for _, w := range words {
+			d := rows.prefixDistance(qw, w)
+			if d < best {
+				best = d
+			}
+		}
+
+		if best > allowed {
+			return 0, false
+		}
+
+		total += best
+	}
+
+	return total, true
+}
+
+func allowedTypos(word string) int {
+	switch n := len(word); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+/*
+distanceRows are the rows prefixDistance works in, reused between calls as it's run on every name for every search
+*/
+type distanceRows struct {
+	prev2, prev, cur []int
+}
+
+/*
+prefixDistance is the smallest edit distance between query and any start of word, so a partly typed word isn't
+penalised for the letters that haven't been typed yet. Swapping two letters counts as one typo.
+Folded names are almost all ASCII so this works on bytes.
+*/
+func (dr *distanceRows) prefixDistance(query string, word string) int {
+	n := len(word) + 1
+
+	if cap(dr.cur) < n {
+		dr.prev2 = make([]int, n)
+		dr.prev = make([]int, n)
+		dr.cur = make([]int, n)
+	}
+
+	prev2, prev, cur := dr.prev2[:n], dr.prev[:n], dr.cur[:n]
+
+	// Optimal string alignment distance, prev[j] is the distance between the query so far and word[:j]
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(query); i++ {
+		cur[0] = i
+
+		for j := 1; j < n; j++ {
+			cost := 1
+			if query[i-1] == word[j-1] {
+				cost = 0
+			}
+
+			d := minInt(prev[j]+1, minInt(cur[j-1]+1, prev[j-1]+cost))
+
+			if i > 1 && j > 1 && query[i-1] == word[j-2] && query[i-2] == word[j-1] {
+				d = minInt(d, prev2[j-2]+1)
+			}
+
+			cur[j] = d
+		}
+
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	best := prev[0]
+	for _, d := range prev {
+		if d < best {
+			best = d
+		}
+	}
+
+	return best
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+/*
+Folded returns FoldName(name), looked up for names that have been indexed
+*/
+func (ci *CardIndex) Folded(name string) string {
+	if folded, ok := ci.folded[name]; ok {
+		return folded
+	}
+
+	return FoldName(name)
+}
+
+/*
+Printings returns the printings of the card with the name that are allowed by include, compared folded so accents
+and punctuation don't matter. Cards with several faces also match the name of their front face,
+e.g. "Delver of Secrets".
+*/
+func (ci *CardIndex) Printings(name string, include Exclusions) []scryfall.Card {
+	key := FoldName(name)
+
 	out := make([]scryfall.Card, 0)
 
-	for _, c := range sharedPrefix {
-		if strings.HasPrefix(strings.ToLower(c.Name), key) {
-			out = append(out, c)
+	if entry, ok := ci.names[key]; ok {
+		out = append(out, entry.allowed(include)...)
+	}
+
+	for _, full := range ci.faces[key] {
+		if full != key {
+			out = append(out, ci.names[full].allowed(include)...)
 		}
 	}
 
@@ -0,0 +1,91 @@
+package data
+
+import (
+	"mtg-bulk-input/internal/scryfall"
+	"reflect"
+	"testing"
+)
+
+func legalCard(name string, set string, number string) scryfall.Card {
+	card := scryfall.Card{Name: name, Set: set, CollectorNumber: number}
+	card.Legalities.Vintage = "legal"
+
+	return card
+}
+
+func testIndex() *CardIndex {
+	ci := NewCardIndex()
+
+	for _, card := range []scryfall.Card{
+		legalCard("Lightning Bolt", "m10", "146"),
+		legalCard("Lightning Bolt", "2xm", "129"),
+		legalCard("Lightning Helix", "rav", "213"),
+		legalCard("Lim-Dûl's Vault", "all", "48"),
+		legalCard("Goblin Guide", "zen", "126"),
+		legalCard("Jötun Grunt", "csp", "8"),
+		{Name: "Goblin", Set: "tm10", CollectorNumber: "4", Layout: "token"},
+	} {
+		ci.Add(card)
+	}
+
+	ci.Sort()
+
+	return ci
+}
+
+func TestSearchNames(t *testing.T) {
+	ci := testIndex()
+
+	tests := []struct {
+		name    string
+		term    string
+		include Exclusions
+		want    []string
+	}{
+		// Too short to match anywhere but the start, so not "Goblin Guide"
+		{"prefix only", "li", IncludeNone, []string{"Lightning Bolt", "Lightning Helix", "Lim-Dûl's Vault"}},
+		{"short word prefix", "bo", IncludeNone, []string{}},
+		{"word prefix", "bolt", IncludeNone, []string{"Lightning Bolt"}},
+		{"substring", "blin", IncludeNone, []string{"Goblin Guide"}},
+		{"punctuation and accents", "lim-dul", IncludeNone, []string{"Lim-Dûl's Vault"}},
+		{"compact", "limdul", IncludeNone, []string{"Lim-Dûl's Vault"}},
+		{"accents left out", "jotun", IncludeNone, []string{"Jötun Grunt"}},
+		{"typo", "lightnig", IncludeNone, []string{"Lightning Bolt", "Lightning Helix"}},
+		{"exact first", "goblin", ExcludedToken, []string{"Goblin", "Goblin Guide"}},
+		{"excluded", "goblin", IncludeNone, []string{"Goblin Guide"}},
+		{"empty", "'", IncludeNone, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+
+			for _, m := range ci.SearchNames(tt.term, tt.include) {
+				got = append(got, m.Name)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SearchNames(%q) = %q, want %q", tt.term, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchPrintingsNewestFirst(t *testing.T) {
+	ci := NewCardIndex()
+
+	old := legalCard("Lightning Bolt", "m10", "146")
+	old.ReleasedAt = "2009-07-17"
+
+	newer := legalCard("Lightning Bolt", "2xm", "129")
+	newer.ReleasedAt = "2020-08-07"
+
+	ci.Add(old)
+	ci.Add(newer)
+	ci.Sort()
+
+	got := ci.Search("lightning bolt", IncludeNone)
+	if len(got) != 2 || got[0].Set != "2xm" || got[1].Set != "m10" {
+		t.Errorf("got %+v, want 2xm then m10", got)
+	}
+}
@@ -0,0 +1,210 @@
+package data
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mtg-bulk-input/internal/scryfall"
+	"os"
+	"path"
+	"time"
+)
+
+const (
+	snapshotFileName = "store.gob"
+
+	// Bump whenever the snapshot layout or the fields kept by trimCard change
+	snapshotVersion = 6
+)
+
+/*
+snapshot is the on-disk form of a Store. The Index isn't stored as it is rebuilt from SetCards on load,
+which is much faster than decoding it.
+*/
+type snapshot struct {
+	Version int
+
+	// UpdatedAt of each bulk type the store was built from, the snapshot is only used if these match meta.json
+	BulkUpdatedAt map[string]time.Time
+
+	Sets          map[string]string
+	SetCards      map[string]map[string]scryfall.Card
+	OracleCards   map[string]scryfall.Card
+	UniqueArtwork map[string]scryfall.Card
+	LangCards     map[string]map[string]map[string]scryfall.Card
+	Rulings       map[string][]scryfall.Ruling
+}
+
+/*
+LoadDataStore loads the store from the snapshot written by a previous run if the bulk data hasn't changed
+since, otherwise it builds the store from the bulk files and writes a new snapshot
+*/
+func LoadDataStore() (Store, error) {
+	updatedAt, err := bulkUpdatedAt()
+	if err != nil {
+		// Without knowing which data we have a snapshot can't be trusted or written
+		log.Printf("not using store snapshot: %v", err)
+		return BuildDataStore()
+	}
+
+	store, err := readSnapshot(updatedAt)
+	if err == nil {
+		return store, nil
+	}
+
+	if !errors.Is(err, errSnapshotStale) && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("ignoring unreadable store snapshot: %v", err)
+	}
+
+	store, err = BuildDataStore()
+	if err != nil {
+		return Store{}, err
+	}
+
+	err = writeSnapshot(store, updatedAt)
+	if err != nil {
+		// Not fatal, the store will be built again next time
+		log.Printf("failed to write store snapshot: %v", err)
+	}
+
+	return store, nil
+}
+
+var errSnapshotStale = errors.New("store snapshot does not match bulk data")
+
+/*
+bulkUpdatedAt reads when each required bulk type was last updated from meta.json
+*/
+func bulkUpdatedAt() (map[string]time.Time, error) {
+	var meta scryfall.BulkDataList
+
+	err := ReadJsonFile(BulkDataDirectory, "meta.json", &meta)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read bulk data metadata: %w", err)
+	}
+
+	out := make(map[string]time.Time, len(requiredBulkFileTypes))
+
+	for _, t := range requiredBulkFileTypes {
+		bd, ok := meta.GetType(t)
+		if !ok {
+			return nil, fmt.Errorf("bulk data metadata does not contain type '%s'", t)
+		}
+
+		out[t] = bd.UpdatedAt
+	}
+
+	return out, nil
+}
+
+func readSnapshot(updatedAt map[string]time.Time) (Store, error) {
+	p := path.Join(WorkingDirectory, BulkDataDirectory, snapshotFileName)
+
+	f, err := os.Open(p)
+	if err != nil {
+		return Store{}, fmt.Errorf("failed to open file '%s': %w", p, err)
+	}
+	defer f.Close()
+
+	dec := gob.NewDecoder(bufio.NewReader(f))
+
+	var snap snapshot
+
+	err = dec.Decode(&snap)
+	if err != nil {
+		return Store{}, fmt.Errorf("failed to decode snapshot '%s': %w", p, err)
+	}
+
+	if snap.Version != snapshotVersion || len(snap.BulkUpdatedAt) != len(updatedAt) {
+		return Store{}, errSnapshotStale
+	}
+
+	for t, u := range updatedAt {
+		if !snap.BulkUpdatedAt[t].Equal(u) {
+			return Store{}, errSnapshotStale
+		}
+	}
+
+	out := Store{
+		Sets:          snap.Sets,
+		SetCards:      snap.SetCards,
+		Index:         NewCardIndex(),
+		OracleCards:   snap.OracleCards,
+		UniqueArtwork: snap.UniqueArtwork,
+		LangCards:     snap.LangCards,
+		Rulings:       snap.Rulings,
+	}
+
+	for _, set := range out.SetCards {
+		for _, c := range set {
+			out.Index.Add(c)
+		}
+	}
+
+	out.Index.Sort()
+
+	return out, nil
+}
+
+func writeSnapshot(store Store, updatedAt map[string]time.Time) error {
+	p := path.Join(WorkingDirectory, BulkDataDirectory, snapshotFileName)
+
+	return WriteFileAtomic(p, 0640, func(w io.Writer) error {
+		err := gob.NewEncoder(w).Encode(snapshot{
+			Version:       snapshotVersion,
+			BulkUpdatedAt: updatedAt,
+			Sets:          store.Sets,
+			SetCards:      store.SetCards,
+			OracleCards:   store.OracleCards,
+			UniqueArtwork: store.UniqueArtwork,
+			LangCards:     store.LangCards,
+			Rulings:       store.Rulings,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to write snapshot '%s': %w", p, err)
+		}
+
+		return nil
+	})
+}
+
+/*
+trimCard keeps only the Card fields the rest of the tool uses, which keeps both the store in memory and
+the snapshot on disk small. Remember to bump snapshotVersion when changing this.
+*/
+func trimCard(c scryfall.Card) scryfall.Card {
+	out := scryfall.Card{
+		Id:              c.Id,
+		OracleId:        c.OracleId,
+		Name:            c.Name,
+		Lang:            c.Lang,
+		Layout:          c.Layout,
+		Legalities:      c.Legalities,
+		Foil:            c.Foil,
+		Nonfoil:         c.Nonfoil,
+		Finishes:        c.Finishes,
+		Set:             c.Set,
+		SetName:         c.SetName,
+		SetType:         c.SetType,
+		CollectorNumber: c.CollectorNumber,
+		ReleasedAt:      c.ReleasedAt,
+		Digital:         c.Digital,
+		Promo:           c.Promo,
+		TypeLine:        c.TypeLine,
+		OracleText:      c.OracleText,
+		Colors:          c.Colors,
+		ColorIdentity:   c.ColorIdentity,
+		Cmc:             c.Cmc,
+		Rarity:          c.Rarity,
+		Artist:          c.Artist,
+		IllustrationId:  c.IllustrationId,
+		Prices:          c.Prices,
+		CardFaces:       c.CardFaces,
+	}
+
+	return out
+}
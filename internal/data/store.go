@@ -1,10 +1,10 @@
 package data
 
 import (
+	"context"
 	"fmt"
 	"mtg-bulk-input/internal/scryfall"
 	"os"
-	"path"
 )
 
 type Store struct {
@@ -14,54 +14,135 @@ type Store struct {
 	// SetCards is keyed: Card.Set -> Card.CollectorNumber
 	SetCards map[string]map[string]scryfall.Card
 
-	Index CardIndex
+	Index *CardIndex
+
+	// OracleCards is keyed: Card.OracleId, only populated when oracle_cards is loaded
+	OracleCards map[string]scryfall.Card
+
+	// UniqueArtwork is keyed: Card.IllustrationId, only populated when unique_artwork is loaded
+	UniqueArtwork map[string]scryfall.Card
+
+	// LangCards holds non-english printings and is keyed: Card.Lang -> Card.Set -> Card.CollectorNumber,
+	// only populated when all_cards is loaded
+	LangCards map[string]map[string]map[string]scryfall.Card
+
+	// Rulings is keyed: Ruling.OracleId, only populated when rulings is loaded
+	Rulings map[string][]scryfall.Ruling
 }
 
 func BuildDataStore() (Store, error) {
 	out := Store{
-		Sets:     make(map[string]string),
-		SetCards: make(map[string]map[string]scryfall.Card),
-		Index:    NewCardIndex(),
+		Sets:          make(map[string]string),
+		SetCards:      make(map[string]map[string]scryfall.Card),
+		Index:         NewCardIndex(),
+		OracleCards:   make(map[string]scryfall.Card),
+		UniqueArtwork: make(map[string]scryfall.Card),
+		LangCards:     make(map[string]map[string]map[string]scryfall.Card),
+		Rulings:       make(map[string][]scryfall.Ruling),
 	}
 
-	p := path.Join(WorkingDirectory, BulkDataDirectory, "default_cards.json")
+	for _, t := range requiredBulkFileTypes {
+		var err error
+
+		switch t {
+		case BulkTypeDefaultCards:
+			err = loadBulkFile(t, out.addDefaultCard)
+		case BulkTypeOracleCards:
+			err = loadBulkFile(t, func(c scryfall.Card) {
+				out.OracleCards[c.OracleId] = trimCard(c)
+			})
+		case BulkTypeUniqueArtwork:
+			err = loadBulkFile(t, func(c scryfall.Card) {
+				out.UniqueArtwork[c.IllustrationId] = trimCard(c)
+			})
+		case BulkTypeAllCards:
+			err = loadBulkFile(t, out.addLangCard)
+		case BulkTypeRulings:
+			err = loadBulkFile(t, func(r scryfall.Ruling) {
+				out.Rulings[r.OracleId] = append(out.Rulings[r.OracleId], r)
+			})
+		default:
+			err = fmt.Errorf("no loader for bulk type")
+		}
 
-	f, err := os.Open(p)
-	if err != nil {
-		return out, fmt.Errorf("failed to open file '%s': %w", p, err)
+		if err != nil {
+			return out, fmt.Errorf("failed to load bulk type '%s': %w", t, err)
+		}
 	}
 
-	chanCards, chanErr := StreamJsonList(f)
+	out.Index.Sort()
+
+	return out, nil
+}
 
-	moreCards := true
+func (s Store) addDefaultCard(c scryfall.Card) {
+	c = trimCard(c)
 
-	for moreCards {
-		select {
-		case c, ok := <-chanCards:
-			if ok {
-				// Populate the mapping of set codes to names
-				out.Sets[c.SetName] = c.Set
+	// Populate the mapping of set codes to names
+	s.Sets[c.SetName] = c.Set
 
-				// Put the card in the SetCards map
-				set, ok := out.SetCards[c.Set]
-				if !ok {
-					set = make(map[string]scryfall.Card)
-				}
+	// Put the card in the SetCards map
+	set, ok := s.SetCards[c.Set]
+	if !ok {
+		set = make(map[string]scryfall.Card)
+	}
 
-				set[c.CollectorNumber] = c
+	set[c.CollectorNumber] = c
 
-				out.SetCards[c.Set] = set
+	s.SetCards[c.Set] = set
 
-				out.Index.Add(c)
-			} else {
-				moreCards = false
-			}
-		case err := <-chanErr:
-			return out, fmt.Errorf("json stream failed: %w", err)
-		}
+	s.Index.Add(c)
+}
+
+func (s Store) addLangCard(c scryfall.Card) {
+	// English printings are already in SetCards from default_cards
+	if c.Lang == "en" {
+		return
 	}
 
-	out.Index.Sort()
+	c = trimCard(c)
 
-	return out, nil
+	sets, ok := s.LangCards[c.Lang]
+	if !ok {
+		sets = make(map[string]map[string]scryfall.Card)
+		s.LangCards[c.Lang] = sets
+	}
+
+	set, ok := sets[c.Set]
+	if !ok {
+		set = make(map[string]scryfall.Card)
+		sets[c.Set] = set
+	}
+
+	set[c.CollectorNumber] = c
+}
+
+/*
+loadBulkFile streams every object in the bulk file for type 't' into fn
+*/
+func loadBulkFile[T any](t string, fn func(T)) error {
+	p := bulkFilePath(t)
+
+	f, err := os.Open(p)
+	if err != nil {
+		return fmt.Errorf("failed to open file '%s': %w", p, err)
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	chanItems, chanErr := StreamJsonList[T](ctx, f)
+
+	for item := range chanItems {
+		fn(item)
+	}
+
+	// The error channel is closed once the stream has finished, so this is nil on success
+	err = <-chanErr
+	if err != nil {
+		return fmt.Errorf("json stream failed: %w", err)
+	}
+
+	return nil
 }
@@ -1,58 +1,223 @@
 package data
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"mtg-bulk-input/internal/scryfall"
-	"strings"
+	"runtime"
+	"sync"
 )
 
-func StreamJsonList(r io.Reader) (<-chan scryfall.Card, <-chan error) {
-	chanCard := make(chan scryfall.Card, 100)
+// How many list items are handed to a decode worker at once
+const streamBatchSize = 256
+
+/*
+streamBatch is a run of raw list items waiting to be unmarshalled by a worker.
+The result is sent on a buffered channel so workers never block on a slow consumer.
+*/
+type streamBatch[T any] struct {
+	start   int
+	offsets []int64
+	raw     []json.RawMessage
+
+	result chan streamBatchResult[T]
+}
+
+type streamBatchResult[T any] struct {
+	items []T
+	err   error
+}
+
+/*
+StreamJsonList decodes each object of a scryfall bulk file (a JSON list) into T.
+The layout of the list doesn't matter, any whitespace and object size is handled.
+
+Splitting the list into raw items happens on one goroutine, while unmarshalling them is spread over a
+worker per CPU. Items are still delivered in the order they appear in the list.
+
+Both channels are closed once the stream ends, an error (if any) is sent on the error channel before
+it is closed. Cancelling ctx stops the stream so the goroutines never outlive an abandoned read.
+*/
+func StreamJsonList[T any](ctx context.Context, r io.Reader) (<-chan T, <-chan error) {
+	return streamJsonList[T](ctx, r, runtime.NumCPU())
+}
+
+func streamJsonList[T any](ctx context.Context, r io.Reader, workers int) (<-chan T, <-chan error) {
+	chanItem := make(chan T, 100)
 	chanErr := make(chan error, 1)
 
+	ctx, cancel := context.WithCancel(ctx)
+
+	// Batches in list order, for the merge loop below to wait on each one in turn
+	ordered := make(chan *streamBatch[T], workers*2)
+	work := make(chan *streamBatch[T], workers*2)
+
+	go splitJsonList(ctx, r, ordered, work)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for b := range work {
+				b.result <- decodeBatch[T](b)
+			}
+		}()
+	}
+
 	go func() {
-		scan := bufio.NewScanner(r)
+		defer close(chanErr)
+		defer close(chanItem)
+		defer wg.Wait()
+		defer cancel()
 
-		line := scan.Scan()
-		if !line {
-			chanErr <- fmt.Errorf("unable to scan first line from reader: %w", scan.Err())
-			return
+		for b := range ordered {
+			var res streamBatchResult[T]
+
+			select {
+			case res = <-b.result:
+			case <-ctx.Done():
+				chanErr <- ctx.Err()
+				return
+			}
+
+			// Any items decoded before a failure are still delivered
+			for _, item := range res.items {
+				select {
+				case chanItem <- item:
+				case <-ctx.Done():
+					chanErr <- ctx.Err()
+					return
+				}
+			}
+
+			if res.err != nil {
+				chanErr <- res.err
+				return
+			}
+		}
+	}()
+
+	return chanItem, chanErr
+}
+
+/*
+splitJsonList reads the raw items out of a JSON list into batches. Each batch is queued on ordered and
+handed to a worker through work, errors are queued on ordered as an already failed batch so every item
+before it is still delivered.
+*/
+func splitJsonList[T any](ctx context.Context, r io.Reader, ordered chan<- *streamBatch[T], work chan<- *streamBatch[T]) {
+	defer close(ordered)
+	defer close(work)
+
+	fail := func(err error) {
+		b := &streamBatch[T]{result: make(chan streamBatchResult[T], 1)}
+		b.result <- streamBatchResult[T]{err: err}
+
+		select {
+		case ordered <- b:
+		case <-ctx.Done():
+		}
+	}
+
+	send := func(b *streamBatch[T]) bool {
+		select {
+		case ordered <- b:
+		case <-ctx.Done():
+			return false
+		}
+
+		select {
+		case work <- b:
+		case <-ctx.Done():
+			return false
 		}
 
-		lineS := scan.Text()
+		return true
+	}
 
-		if !strings.HasPrefix(lineS, "[") {
-			chanErr <- fmt.Errorf("first line on reader was not an opening list brace ('['), got: %s", lineS)
-			return
+	newBatch := func(start int) *streamBatch[T] {
+		return &streamBatch[T]{
+			start:   start,
+			offsets: make([]int64, 0, streamBatchSize),
+			raw:     make([]json.RawMessage, 0, streamBatchSize),
+			result:  make(chan streamBatchResult[T], 1),
 		}
+	}
+
+	dec := json.NewDecoder(r)
+
+	tok, err := dec.Token()
+	if err != nil {
+		fail(fmt.Errorf("unable to read opening token of list: %w", err))
+		return
+	}
+
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		fail(fmt.Errorf("expected an opening list brace ('[') at byte offset %d, got: %v", dec.InputOffset(), tok))
+		return
+	}
 
-		// While more lines
-		for scan.Scan() {
-			lineB := scan.Bytes()
+	b := newBatch(0)
 
-			if bytes.HasPrefix(lineB, []byte("]")) {
-				break
+	for i := 0; dec.More(); i++ {
+		offset := dec.InputOffset()
+
+		var raw json.RawMessage
+
+		err = dec.Decode(&raw)
+		if err != nil {
+			if len(b.raw) > 0 && !send(b) {
+				return
 			}
 
-			lineB = bytes.TrimSuffix(bytes.TrimSpace(lineB), []byte(","))
+			fail(fmt.Errorf("failed to decode item %d starting near byte offset %d: %w", i, offset, err))
+			return
+		}
 
-			var card scryfall.Card
+		b.offsets = append(b.offsets, offset)
+		b.raw = append(b.raw, raw)
 
-			err := json.Unmarshal(lineB, &card)
-			if err != nil {
-				chanErr <- fmt.Errorf("failed to unmarshal json from stream: %w", err)
+		if len(b.raw) == streamBatchSize {
+			if !send(b) {
 				return
 			}
 
-			chanCard <- card
+			b = newBatch(i + 1)
 		}
+	}
 
-		close(chanCard)
-	}()
+	if len(b.raw) > 0 && !send(b) {
+		return
+	}
+
+	tok, err = dec.Token()
+	if err != nil {
+		fail(fmt.Errorf("unable to read closing token of list at byte offset %d: %w", dec.InputOffset(), err))
+		return
+	}
+
+	if delim, ok := tok.(json.Delim); !ok || delim != ']' {
+		fail(fmt.Errorf("expected a closing list brace (']') at byte offset %d, got: %v", dec.InputOffset(), tok))
+		return
+	}
+}
+
+func decodeBatch[T any](b *streamBatch[T]) streamBatchResult[T] {
+	items := make([]T, len(b.raw))
+
+	for i, raw := range b.raw {
+		err := json.Unmarshal(raw, &items[i])
+		if err != nil {
+			return streamBatchResult[T]{
+				items: items[:i],
+				err:   fmt.Errorf("failed to decode item %d starting near byte offset %d: %w", b.start+i, b.offsets[i], err),
+			}
+		}
+	}
 
-	return chanCard, chanErr
+	return streamBatchResult[T]{items: items}
 }
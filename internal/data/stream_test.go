@@ -0,0 +1,204 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mtg-bulk-input/internal/scryfall"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// How many cards the benchmark decodes, around a fifth of a default_cards bulk file
+const benchmarkCards = 20000
+
+func readFixture(t testing.TB) []byte {
+	fixture, err := os.ReadFile("testdata/cards.json")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+
+	return fixture
+}
+
+/*
+repeatList builds a JSON list of n items by cycling through the items of list, one item per line like a bulk file
+*/
+func repeatList(t testing.TB, list []byte, n int) []byte {
+	var items []json.RawMessage
+
+	err := json.Unmarshal(list, &items)
+	if err != nil {
+		t.Fatalf("failed to split list: %v", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("[\n")
+
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteString(",\n")
+		}
+
+		buf.Write(items[i%len(items)])
+	}
+
+	buf.WriteString("\n]\n")
+
+	return buf.Bytes()
+}
+
+func collect[T any](t testing.TB, r *bytes.Reader, workers int) []T {
+	chanItems, chanErr := streamJsonList[T](context.Background(), r, workers)
+
+	out := make([]T, 0)
+	for item := range chanItems {
+		out = append(out, item)
+	}
+
+	if err := <-chanErr; err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+
+	return out
+}
+
+/*
+TestStreamJsonListOrder checks that items come out in list order however many workers decode them, across several
+batches of different sizes
+*/
+func TestStreamJsonListOrder(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("[")
+
+	n := streamBatchSize*5 + 17
+
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+
+		// Items of different sizes so batches take different times to decode
+		fmt.Fprintf(&sb, `{"n":%d,"pad":"%s"}`, i, strings.Repeat("x", (i*37)%500))
+	}
+
+	sb.WriteString("]")
+
+	type item struct {
+		N int `json:"n"`
+	}
+
+	for _, workers := range []int{1, 4, 16} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			items := collect[item](t, bytes.NewReader([]byte(sb.String())), workers)
+
+			if len(items) != n {
+				t.Fatalf("got %d items, want %d", len(items), n)
+			}
+
+			for i, it := range items {
+				if it.N != i {
+					t.Fatalf("item %d is %d, out of order", i, it.N)
+				}
+			}
+		})
+	}
+}
+
+/*
+TestStreamJsonListFixture checks the stream decodes the fixture exactly as unmarshalling the whole list does
+*/
+func TestStreamJsonListFixture(t *testing.T) {
+	fixture := readFixture(t)
+
+	var want []scryfall.Card
+
+	err := json.Unmarshal(fixture, &want)
+	if err != nil {
+		t.Fatalf("failed to unmarshal fixture: %v", err)
+	}
+
+	got := collect[scryfall.Card](t, bytes.NewReader(fixture), 4)
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d cards, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		if got[i].Id != want[i].Id || got[i].Name != want[i].Name {
+			t.Fatalf("card %d is %s (%s), want %s (%s)", i, got[i].Name, got[i].Id, want[i].Name, want[i].Id)
+		}
+	}
+}
+
+/*
+TestStreamJsonListError checks the items before a bad item are still delivered, followed by the error
+*/
+func TestStreamJsonListError(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("[")
+
+	bad := streamBatchSize + 3
+
+	for i := 0; i < bad; i++ {
+		fmt.Fprintf(&sb, `{"n":%d},`, i)
+	}
+
+	sb.WriteString(`{"n":"not a number"}]`)
+
+	type item struct {
+		N int `json:"n"`
+	}
+
+	chanItems, chanErr := streamJsonList[item](context.Background(), strings.NewReader(sb.String()), 4)
+
+	n := 0
+	for range chanItems {
+		n++
+	}
+
+	if n != bad {
+		t.Errorf("got %d items before the error, want %d", n, bad)
+	}
+
+	err := <-chanErr
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("item %d", bad)) {
+		t.Errorf("got error %v, want one for item %d", err, bad)
+	}
+}
+
+/*
+BenchmarkStreamJsonList decodes benchmarkCards cards, made by repeating testdata/cards.json (a slice of a
+default_cards bulk file), with a single decode worker and with the worker per CPU StreamJsonList uses
+*/
+func BenchmarkStreamJsonList(b *testing.B) {
+	input := repeatList(b, readFixture(b), benchmarkCards)
+
+	pools := []struct {
+		name    string
+		workers int
+	}{
+		{"single", 1},
+		{fmt.Sprintf("pool-%d", runtime.NumCPU()), runtime.NumCPU()},
+	}
+
+	for _, pool := range pools {
+		workers := pool.workers
+
+		b.Run(pool.name, func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				cards := collect[scryfall.Card](b, bytes.NewReader(input), workers)
+
+				if len(cards) != benchmarkCards {
+					b.Fatalf("decoded %d cards, want %d", len(cards), benchmarkCards)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package deckbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mtg-bulk-input/internal/data"
+	"mtg-bulk-input/internal/scryfall"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Name of the edition override file looked for in the data working directory
+const EditionOverrideFileName = "editions.json"
+
+/*
+builtinEditionsByCode maps scryfall set codes to Deckbox edition names where the two differ.
+Add to this as mismatches are found, one-off fixes belong in the override file instead.
+*/
+var builtinEditionsByCode = map[string]string{
+	"lea":   "Alpha Edition",
+	"leb":   "Beta Edition",
+	"tsb":   "Time Spiral \"Timeshifted\"",
+	"sld":   "Secret Lair Drop Series",
+	"cmd":   "Commander",
+	"olgc":  "Legacy Championship",
+	"pmei":  "Media Inserts",
+	"pgru":  "Guru",
+	"parl":  "Arena League",
+	"pal99": "Arena League",
+	"pal00": "Arena League",
+	"pal01": "Arena League",
+	"pal02": "Arena League",
+	"pal03": "Arena League",
+	"pal04": "Arena League",
+	"pal05": "Arena League",
+	"pal06": "Arena League",
+}
+
+/*
+builtinEditionsByNamePrefix maps families of scryfall set names (e.g. "Friday Night Magic 2009") onto a single
+Deckbox edition
+*/
+var builtinEditionsByNamePrefix = map[string]string{
+	"Friday Night Magic ":   "Friday Night Magic",
+	"Judge Gift Cards ":     "Judge Gift Program",
+	"Magic Player Rewards ": "Magic Player Rewards",
+	"Wizards Play Network ": "Wizards Play Network",
+	"Grand Prix Promos":     "Grand Prix",
+	"Pro Tour Promos":       "Pro Tour",
+}
+
+/*
+Set types where scryfall and Deckbox tend to disagree, a card from one of these without an explicit mapping is
+reported as unmapped so it can be checked before importing
+*/
+var unreliableSetTypes = map[string]bool{
+	"promo":          true,
+	"token":          true,
+	"memorabilia":    true,
+	"box":            true,
+	"funny":          true,
+	"minigame":       true,
+	"treasure_chest": true,
+}
+
+/*
+EditionOverrides is the format of the user's override file, both maps take precedence over the built-in table
+*/
+type EditionOverrides struct {
+	// Keyed: scryfall set code
+	Codes map[string]string `json:"codes"`
+
+	// Keyed: scryfall set name
+	Names map[string]string `json:"names"`
+}
+
+/*
+EditionMapper translates a card's scryfall set into the Deckbox edition
+*/
+type EditionMapper struct {
+	overrides EditionOverrides
+}
+
+/*
+LoadEditionMapper reads the override file at path. If path is empty editions.json in the data working directory
+is used, and it not existing is not an error.
+*/
+func LoadEditionMapper(path string) (EditionMapper, error) {
+	var overrides EditionOverrides
+
+	if path == "" {
+		err := data.ReadJsonFile("", EditionOverrideFileName, &overrides)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return EditionMapper{}, fmt.Errorf("failed to read edition overrides: %w", err)
+		}
+	} else {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return EditionMapper{}, fmt.Errorf("failed to read edition overrides '%s': %w", path, err)
+		}
+
+		err = json.Unmarshal(b, &overrides)
+		if err != nil {
+			return EditionMapper{}, fmt.Errorf("failed to parse edition overrides '%s': %w", path, err)
+		}
+	}
+
+	return EditionMapper{overrides: overrides}, nil
+}
+
+/*
+Edition returns the Deckbox edition for the card, and whether it came from a mapping. When it didn't the
+scryfall set name is returned, which is right for the vast majority of sets.
+*/
+func (em EditionMapper) Edition(card scryfall.Card) (string, bool) {
+	if e, ok := em.overrides.Codes[card.Set]; ok {
+		return e, true
+	}
+
+	if e, ok := em.overrides.Names[card.SetName]; ok {
+		return e, true
+	}
+
+	if e, ok := builtinEditionsByCode[card.Set]; ok {
+		return e, true
+	}
+
+	for prefix, e := range builtinEditionsByNamePrefix {
+		if strings.HasPrefix(card.SetName, prefix) {
+			return e, true
+		}
+	}
+
+	// Deckbox files tokens and other extras for a set under "Extras: <Set Name>"
+	if card.SetType == "token" && strings.HasSuffix(card.SetName, " Tokens") {
+		return "Extras: " + strings.TrimSuffix(card.SetName, " Tokens"), true
+	}
+
+	return card.SetName, false
+}
+
+/*
+UnmappedEditions lists the scryfall set names of the selected cards that are likely to be named differently
+on Deckbox and have no mapping, sorted by name
+*/
+func (em EditionMapper) UnmappedEditions(cards []SelectedCard, store data.Store) []string {
+	seen := make(map[string]bool)
+	out := make([]string, 0)
+
+	for _, sCard := range cards {
+		card, ok := store.SetCards[sCard.Set][sCard.Number]
+		if !ok || seen[card.SetName] {
+			continue
+		}
+
+		seen[card.SetName] = true
+
+		if _, mapped := em.Edition(card); !mapped && unreliableSetTypes[card.SetType] {
+			out = append(out, card.SetName)
+		}
+	}
+
+	sort.Strings(out)
+
+	return out
+}
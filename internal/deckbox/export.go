@@ -2,69 +2,260 @@ package deckbox
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"mtg-bulk-input/internal/data"
-	"os"
-	"strings"
+	"mtg-bulk-input/internal/scryfall"
 )
 
+/*
+Conditions are the card conditions Deckbox accepts, an empty condition leaves it to Deckbox's default
+*/
+var Conditions = []string{
+	"Mint",
+	"Near Mint",
+	"Good (Lightly Played)",
+	"Played",
+	"Heavily Played",
+	"Poor",
+}
+
+/*
+Languages are the card languages Deckbox accepts, an empty language is treated as English
+*/
+var Languages = []string{
+	"English",
+	"French",
+	"German",
+	"Italian",
+	"Japanese",
+	"Korean",
+	"Portuguese",
+	"Russian",
+	"Simplified Chinese",
+	"Spanish",
+	"Traditional Chinese",
+}
+
+var exportHeader = []string{
+	"Count",
+	"Tradelist Count",
+	"Name",
+	"Edition",
+	"Card Number",
+	"Condition",
+	"Language",
+	"Foil",
+	"Signed",
+	"Artist Proof",
+	"Altered Art",
+	"Misprint",
+	"Promo",
+	"Textless",
+	"My Price",
+}
+
 type SelectedCard struct {
 	Set      string
 	Quantity int
 	Number   string
-	Foil     bool
+	Finish   Finish
+
+	// How many of Quantity are on the tradelist
+	TradelistCount int
+
+	Condition   string
+	Language    string
+	Signed      bool
+	ArtistProof bool
+	AlteredArt  bool
+	Misprint    bool
+	Promo       bool
+	Textless    bool
+
+	// Free text, exported as is
+	MyPrice string
 }
 
-func Export(path string, cards []SelectedCard, store data.Store) error {
-	out := make([][]string, 1, len(cards)+1)
+/*
+SameCard reports whether two selected cards are the same printing with the same attributes,
+i.e. whether they belong on the same row with their quantities combined
+*/
+func (sc SelectedCard) SameCard(other SelectedCard) bool {
+	sc.Quantity, other.Quantity = 0, 0
+	sc.TradelistCount, other.TradelistCount = 0, 0
 
-	// Set the header
-	out[0] = make([]string, 5)
+	return sc == other
+}
 
-	out[0][0] = "Count"
-	out[0][1] = "Name"
-	out[0][2] = "Edition"
-	out[0][3] = "Card Number"
-	out[0][4] = "Foil"
+/*
+RowError is a selected card that couldn't be exported, Row is its index in the list being exported
+*/
+type RowError struct {
+	Row  int
+	Card SelectedCard
+	Err  error
+}
 
-	for _, sCard := range cards {
-		row := make([]string, 5)
+/*
+ExportError is returned by an export when any rows couldn't be exported, nothing is written in that case
+*/
+type ExportError struct {
+	Rows []RowError
+}
 
-		card, ok := store.SetCards[sCard.Set][sCard.Number]
-		if !ok {
-			return fmt.Errorf("could not find card '%s' in set '%s'", sCard.Number, sCard.Set)
+func (e ExportError) Error() string {
+	return fmt.Sprintf("%d rows could not be exported", len(e.Rows))
+}
+
+/*
+ErrUnmappedEdition is the RowError for a card whose edition may be named differently on Deckbox, see
+EditionMapper.UnmappedEditions and Exporter.AllowUnmapped
+*/
+var ErrUnmappedEdition = errors.New("edition may not match Deckbox")
+
+/*
+OnlyUnmappedEditions reports whether err is an ExportError for nothing but ErrUnmappedEdition rows, in which case
+the export would work with Exporter.AllowUnmapped set
+*/
+func OnlyUnmappedEditions(err error) bool {
+	var exportErr ExportError
+	if !errors.As(err, &exportErr) || len(exportErr.Rows) == 0 {
+		return false
+	}
+
+	for _, re := range exportErr.Rows {
+		if !errors.Is(re.Err, ErrUnmappedEdition) {
+			return false
 		}
+	}
+
+	return true
+}
 
-		row[0] = fmt.Sprint(sCard.Quantity)
-		row[1] = card.Name    // TODO: Handle two sided-cards?
-		row[2] = card.SetName // TODO: Handle SetName mapping?
-		row[3] = card.CollectorNumber
+/*
+ResolveCards looks up the scryfall card for each selected card, returning an ExportError listing
+every card that couldn't be found
+*/
+func ResolveCards(cards []SelectedCard, store data.Store) ([]scryfall.Card, error) {
+	out := make([]scryfall.Card, 0, len(cards))
+	rowErrors := make([]RowError, 0)
 
-		foilS := ""
-		if sCard.Foil {
-			foilS = "foil"
+	for i, sCard := range cards {
+		card, ok := store.SetCards[sCard.Set][sCard.Number]
+		if !ok {
+			rowErrors = append(rowErrors, RowError{
+				Row:  i,
+				Card: sCard,
+				Err:  fmt.Errorf("could not find card '%s' in set '%s'", sCard.Number, sCard.Set),
+			})
+			continue
 		}
 
-		row[4] = foilS
+		out = append(out, card)
+	}
 
-		out = append(out, row)
+	if len(rowErrors) > 0 {
+		return nil, ExportError{Rows: rowErrors}
 	}
 
-	// Create file name
-	csvPath := fmt.Sprintf("%s.csv", strings.TrimSuffix(path, ".json"))
+	return out, nil
+}
+
+/*
+Exporter writes cards as a Deckbox inventory CSV. Cards in editions that may be named differently on Deckbox fail
+with ErrUnmappedEdition unless AllowUnmapped is set.
+*/
+type Exporter struct {
+	Editions EditionMapper
+
+	AllowUnmapped bool
+}
+
+func NewExporter(editions EditionMapper) Exporter {
+	return Exporter{Editions: editions}
+}
+
+// The name of the Deckbox Exporter, which has to be registered with the user's edition overrides
+const ExporterName = "deckbox"
 
-	// Open file
-	f, err := os.OpenFile(csvPath, os.O_CREATE|os.O_RDWR, 0755)
+func (e Exporter) Name() string {
+	return ExporterName
+}
+
+func (e Exporter) Extension() string {
+	return "csv"
+}
+
+func (e Exporter) Export(cards []SelectedCard, store data.Store, w io.Writer) error {
+	resolved, err := ResolveCards(cards, store)
 	if err != nil {
-		return fmt.Errorf("failed to open output file: %w", err)
+		return err
 	}
 
-	w := csv.NewWriter(f)
+	out := make([][]string, 1, len(cards)+1)
+	rowErrors := make([]RowError, 0)
+
+	// Set the header
+	out[0] = exportHeader
 
-	err = w.WriteAll(out)
+	for i, sCard := range cards {
+		card := resolved[i]
+
+		edition, mapped := e.Editions.Edition(card)
+		if !mapped && !e.AllowUnmapped && unreliableSetTypes[card.SetType] {
+			rowErrors = append(rowErrors, RowError{
+				Row:  i,
+				Card: sCard,
+				Err:  fmt.Errorf("%w: '%s', add it to %s", ErrUnmappedEdition, card.SetName, EditionOverrideFileName),
+			})
+			continue
+		}
+
+		tradelistCount := ""
+		if sCard.TradelistCount > 0 {
+			tradelistCount = fmt.Sprint(sCard.TradelistCount)
+		}
+
+		out = append(out, []string{
+			fmt.Sprint(sCard.Quantity),
+			tradelistCount,
+			CardName(card),
+			edition,
+			card.CollectorNumber,
+			sCard.Condition,
+			sCard.Language,
+			sCard.Finish.DeckboxValue(),
+			flag(sCard.Signed, "signed"),
+			flag(sCard.ArtistProof, "proof"),
+			flag(sCard.AlteredArt, "altered"),
+			flag(sCard.Misprint, "misprint"),
+			flag(sCard.Promo, "promo"),
+			flag(sCard.Textless, "textless"),
+			sCard.MyPrice,
+		})
+	}
+
+	if len(rowErrors) > 0 {
+		return ExportError{Rows: rowErrors}
+	}
+
+	err = csv.NewWriter(w).WriteAll(out)
 	if err != nil {
-		return fmt.Errorf("failed to write CSV file: %w", err)
+		return fmt.Errorf("failed to write CSV: %w", err)
 	}
 
 	return nil
 }
+
+/*
+flag returns the value Deckbox expects in a yes/no column, which is blank for no
+*/
+func flag(set bool, value string) string {
+	if set {
+		return value
+	}
+
+	return ""
+}
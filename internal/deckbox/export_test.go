@@ -0,0 +1,65 @@
+package deckbox
+
+import (
+	"bytes"
+	"errors"
+	"mtg-bulk-input/internal/data"
+	"mtg-bulk-input/internal/scryfall"
+	"strings"
+	"testing"
+)
+
+func TestExportUnmappedEditions(t *testing.T) {
+	store := data.Store{
+		SetCards: map[string]map[string]scryfall.Card{
+			"m10":  {"146": {Name: "Lightning Bolt", Set: "m10", SetName: "Magic 2010", SetType: "core", CollectorNumber: "146"}},
+			"pxyz": {"1": {Name: "Lightning Bolt", Set: "pxyz", SetName: "Some Promos", SetType: "promo", CollectorNumber: "1"}},
+		},
+	}
+
+	cards := []SelectedCard{
+		{Set: "m10", Number: "146", Quantity: 1, Finish: FinishNonfoil},
+		{Set: "pxyz", Number: "1", Quantity: 1, Finish: FinishNonfoil},
+	}
+
+	var buf bytes.Buffer
+
+	err := NewExporter(EditionMapper{}).Export(cards, store, &buf)
+	if !OnlyUnmappedEditions(err) {
+		t.Fatalf("got error %v, want only unmapped editions", err)
+	}
+
+	var exportErr ExportError
+	if !errors.As(err, &exportErr) || len(exportErr.Rows) != 1 || exportErr.Rows[0].Row != 1 {
+		t.Fatalf("got rows %+v, want row 1", exportErr.Rows)
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("wrote %q after failing", buf.String())
+	}
+
+	exporter := NewExporter(EditionMapper{})
+	exporter.AllowUnmapped = true
+
+	err = exporter.Export(cards, store, &buf)
+	if err != nil {
+		t.Fatalf("export allowing unmapped editions failed: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "Some Promos") || !strings.Contains(buf.String(), "Magic 2010") {
+		t.Errorf("export is missing a card:\n%s", buf.String())
+	}
+}
+
+func TestOnlyUnmappedEditions(t *testing.T) {
+	missing := RowError{Err: errors.New("could not find card")}
+	unmapped := RowError{Err: ErrUnmappedEdition}
+
+	if OnlyUnmappedEditions(ExportError{Rows: []RowError{unmapped, missing}}) {
+		t.Error("a missing card was treated as an unmapped edition")
+	}
+
+	if OnlyUnmappedEditions(errors.New("disk full")) {
+		t.Error("an error other than ExportError was treated as an unmapped edition")
+	}
+}
@@ -0,0 +1,121 @@
+package deckbox
+
+import (
+	"encoding/json"
+	"mtg-bulk-input/internal/scryfall"
+)
+
+/*
+Finish is the finish of a physical card, the values match scryfall's Card.Finishes
+*/
+type Finish string
+
+const (
+	FinishNonfoil Finish = "nonfoil"
+	FinishFoil    Finish = "foil"
+	FinishEtched  Finish = "etched"
+	FinishGlossy  Finish = "glossy"
+)
+
+var Finishes = []Finish{
+	FinishNonfoil,
+	FinishFoil,
+	FinishEtched,
+	FinishGlossy,
+}
+
+/*
+FinishFromSuffix maps the suffix used when entering cards (e.g. "123f") to a finish,
+no suffix is nonfoil
+*/
+func FinishFromSuffix(suffix string) (Finish, bool) {
+	switch suffix {
+	case "":
+		return FinishNonfoil, true
+	case "f":
+		return FinishFoil, true
+	case "e":
+		return FinishEtched, true
+	case "g":
+		return FinishGlossy, true
+	default:
+		return "", false
+	}
+}
+
+/*
+AvailableFor reports whether the card was printed with this finish
+*/
+func (f Finish) AvailableFor(card scryfall.Card) bool {
+	for _, cf := range card.Finishes {
+		if Finish(cf) == f {
+			return true
+		}
+	}
+
+	// Fall back to the older booleans for cards without finishes
+	if len(card.Finishes) == 0 {
+		return f == FinishFoil && card.Foil || f == FinishNonfoil && card.Nonfoil
+	}
+
+	return false
+}
+
+/*
+Price returns the scryfall USD price of the card in this finish, glossy cards aren't priced separately
+*/
+func (f Finish) Price(card scryfall.Card) string {
+	switch f {
+	case FinishFoil:
+		return card.Prices.UsdFoil
+	case FinishEtched:
+		return card.Prices.UsdEtched
+	default:
+		return card.Prices.Usd
+	}
+}
+
+/*
+DeckboxValue is the value of Deckbox's Foil column for this finish
+*/
+func (f Finish) DeckboxValue() string {
+	switch f {
+	case FinishFoil:
+		return "foil"
+	case FinishEtched:
+		return "etched"
+	default:
+		// Deckbox has no glossy finish, they're imported as regular cards
+		return ""
+	}
+}
+
+/*
+UnmarshalJSON reads a SelectedCard, upgrading session files saved before Finish replaced the Foil flag
+*/
+func (sc *SelectedCard) UnmarshalJSON(b []byte) error {
+	// Alias drops the methods so this isn't called recursively
+	type selectedCard SelectedCard
+
+	var legacy struct {
+		selectedCard
+		Foil bool
+	}
+
+	err := json.Unmarshal(b, &legacy)
+	if err != nil {
+		return err
+	}
+
+	*sc = SelectedCard(legacy.selectedCard)
+
+	if sc.Finish == "" {
+		if legacy.Foil {
+			sc.Finish = FinishFoil
+		} else {
+			sc.Finish = FinishNonfoil
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,252 @@
+package deckbox
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mtg-bulk-input/internal/data"
+	"mtg-bulk-input/internal/scryfall"
+	"strconv"
+	"strings"
+)
+
+/*
+ImportRow is the outcome of importing one row of a Deckbox CSV, Err is set if it couldn't be resolved
+*/
+type ImportRow struct {
+	Line int
+	Name string
+	Card SelectedCard
+	Err  error
+}
+
+/*
+ImportRows reads a Deckbox inventory CSV, as exported by Deckbox or by this tool, resolving each row to a printing
+in the store by its Edition, Card Number and Name. The outcome of every row is returned in the order they appear,
+rows that can't be resolved have the reason in Err. An error is only returned if the CSV itself is unusable.
+*/
+func ImportRows(r io.Reader, store data.Store, editions EditionMapper) ([]ImportRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[strings.TrimSpace(h)] = i
+	}
+
+	for _, required := range []string{"Count", "Name", "Edition"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV has no '%s' column, is it a Deckbox export?", required)
+		}
+	}
+
+	resolver := newEditionResolver(store, editions)
+
+	out := make([]ImportRow, 0)
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+
+		line, _ := cr.FieldPos(0)
+
+		get := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+
+			return strings.TrimSpace(record[i])
+		}
+
+		name := get("Name")
+
+		// Deckbox exports end with blank rows
+		if name == "" && get("Count") == "" {
+			continue
+		}
+
+		sCard, err := resolveRow(get, resolver)
+
+		out = append(out, ImportRow{
+			Line: line,
+			Name: name,
+			Card: sCard,
+			Err:  err,
+		})
+	}
+
+	return out, nil
+}
+
+func resolveRow(get func(column string) string, resolver editionResolver) (SelectedCard, error) {
+	count, err := strconv.Atoi(get("Count"))
+	if err != nil || count < 1 {
+		return SelectedCard{}, fmt.Errorf("invalid count '%s'", get("Count"))
+	}
+
+	tradelistCount := 0
+	if tc := get("Tradelist Count"); tc != "" {
+		tradelistCount, err = strconv.Atoi(tc)
+		if err != nil || tradelistCount < 0 {
+			return SelectedCard{}, fmt.Errorf("invalid tradelist count '%s'", tc)
+		}
+	}
+
+	finish, err := finishFromDeckboxValue(get("Foil"))
+	if err != nil {
+		return SelectedCard{}, err
+	}
+
+	card, err := resolver.resolve(get("Edition"), get("Card Number"), get("Name"))
+	if err != nil {
+		return SelectedCard{}, err
+	}
+
+	if !finish.AvailableFor(card) {
+		return SelectedCard{}, fmt.Errorf("%s - %s was not printed in finish '%s'", card.Set, card.CollectorNumber, finish)
+	}
+
+	return SelectedCard{
+		Set:            card.Set,
+		Quantity:       count,
+		Number:         card.CollectorNumber,
+		Finish:         finish,
+		TradelistCount: tradelistCount,
+		Condition:      get("Condition"),
+		Language:       get("Language"),
+		Signed:         get("Signed") != "",
+		ArtistProof:    get("Artist Proof") != "",
+		AlteredArt:     get("Altered Art") != "",
+		Misprint:       get("Misprint") != "",
+		Promo:          get("Promo") != "",
+		Textless:       get("Textless") != "",
+		MyPrice:        get("My Price"),
+	}, nil
+}
+
+func finishFromDeckboxValue(v string) (Finish, error) {
+	switch strings.ToLower(v) {
+	case "":
+		return FinishNonfoil, nil
+	case "foil":
+		return FinishFoil, nil
+	case "etched":
+		return FinishEtched, nil
+	default:
+		return "", fmt.Errorf("unknown foil value '%s'", v)
+	}
+}
+
+/*
+editionResolver maps Deckbox edition names back to the scryfall sets they could have come from,
+using the same EditionMapper as exporting so the two round trip
+*/
+type editionResolver struct {
+	store data.Store
+
+	// Keyed: Deckbox edition, lower case
+	sets map[string][]string
+}
+
+func newEditionResolver(store data.Store, editions EditionMapper) editionResolver {
+	er := editionResolver{
+		store: store,
+		sets:  make(map[string][]string),
+	}
+
+	for set, cards := range store.SetCards {
+		// The edition only depends on the set, so any card will do
+		for _, card := range cards {
+			edition, _ := editions.Edition(card)
+			key := strings.ToLower(edition)
+
+			er.sets[key] = append(er.sets[key], set)
+
+			// The plain scryfall name is accepted as well, in case the CSV didn't come from Deckbox
+			if !strings.EqualFold(edition, card.SetName) {
+				key = strings.ToLower(card.SetName)
+				er.sets[key] = append(er.sets[key], set)
+			}
+
+			break
+		}
+	}
+
+	return er
+}
+
+func (er editionResolver) resolve(edition, number, name string) (scryfall.Card, error) {
+	sets, ok := er.sets[strings.ToLower(edition)]
+	if !ok {
+		return scryfall.Card{}, fmt.Errorf("unknown edition '%s'", edition)
+	}
+
+	matches := make([]scryfall.Card, 0)
+
+	for _, set := range sets {
+		if number != "" {
+			card, ok := LookupNumber(er.store.SetCards[set], number)
+			if ok && NamesMatch(card, name) {
+				matches = append(matches, card)
+			}
+			continue
+		}
+
+		for _, card := range er.store.SetCards[set] {
+			if NamesMatch(card, name) {
+				matches = append(matches, card)
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		if number != "" {
+			return scryfall.Card{}, fmt.Errorf("no card '%s' numbered %s in edition '%s'", name, number, edition)
+		}
+		return scryfall.Card{}, fmt.Errorf("no card '%s' in edition '%s'", name, edition)
+	case 1:
+		return matches[0], nil
+	default:
+		return scryfall.Card{}, fmt.Errorf("%d printings of '%s' in edition '%s', a card number is needed", len(matches), name, edition)
+	}
+}
+
+/*
+LookupNumber finds a collector number in a set's cards. The number is tried as given first, then without leading
+zeros and in lower case (e.g. "0123A" finds "123a"). A number is never looked up as "", so "0" only finds "0".
+*/
+func LookupNumber(cards map[string]scryfall.Card, number string) (scryfall.Card, bool) {
+	trimmed := strings.TrimLeft(number, "0")
+
+	for _, n := range []string{number, trimmed, strings.ToLower(number), strings.ToLower(trimmed)} {
+		if n == "" {
+			continue
+		}
+
+		if card, ok := cards[n]; ok {
+			return card, true
+		}
+	}
+
+	return scryfall.Card{}, false
+}
+
+/*
+NamesMatch compares a CSV name to a card, accepting both the Deckbox name and the full scryfall name
+*/
+func NamesMatch(card scryfall.Card, name string) bool {
+	return strings.EqualFold(CardName(card), name) || strings.EqualFold(card.Name, name)
+}
@@ -0,0 +1,37 @@
+package deckbox
+
+import (
+	"mtg-bulk-input/internal/scryfall"
+	"testing"
+)
+
+func TestLookupNumber(t *testing.T) {
+	cards := map[string]scryfall.Card{
+		"0":    {CollectorNumber: "0"},
+		"7":    {CollectorNumber: "7"},
+		"007":  {CollectorNumber: "007"},
+		"123a": {CollectorNumber: "123a"},
+	}
+
+	tests := []struct {
+		number string
+		want   string
+		ok     bool
+	}{
+		{"0", "0", true},
+		{"00", "", false},
+		{"7", "7", true},
+		{"07", "7", true},
+		{"007", "007", true},
+		{"0123A", "123a", true},
+		{"8", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		card, ok := LookupNumber(cards, tt.number)
+		if ok != tt.ok || card.CollectorNumber != tt.want {
+			t.Errorf("LookupNumber(%q) = %q, %v, want %q, %v", tt.number, card.CollectorNumber, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package deckbox
+
+import (
+	"mtg-bulk-input/internal/scryfall"
+	"strings"
+)
+
+/*
+CardName converts a scryfall card name into the name Deckbox's importer expects.
+
+Scryfall names every multi-faced card "Front // Back", but Deckbox only does that for cards where both halves
+are on the same face (split and aftermath). Transform, modal double faced, flip and adventure cards are known
+by their front face, and each half of a meld pair is its own card already.
+*/
+func CardName(card scryfall.Card) string {
+	switch card.Layout {
+	case "split", "aftermath":
+		return card.Name
+	case "transform", "modal_dfc", "flip", "adventure", "reversible_card", "double_faced_token":
+		return frontFaceName(card)
+	default:
+		// Includes meld, where each card already has its own name
+		return card.Name
+	}
+}
+
+func frontFaceName(card scryfall.Card) string {
+	if len(card.CardFaces) > 0 && card.CardFaces[0].Name != "" {
+		return card.CardFaces[0].Name
+	}
+
+	// No faces to go from, take everything before the first separator
+	front, _, _ := strings.Cut(card.Name, " // ")
+
+	return front
+}
@@ -0,0 +1,85 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"mtg-bulk-input/internal/data"
+	"mtg-bulk-input/internal/deckbox"
+)
+
+// Deckbox condition -> Archidekt condition
+var archidektConditions = map[string]string{
+	"Mint":                  "M",
+	"Near Mint":             "NM",
+	"Good (Lightly Played)": "LP",
+	"Played":                "MP",
+	"Heavily Played":        "HP",
+	"Poor":                  "D",
+}
+
+// Finish -> Archidekt finish
+var archidektFinishes = map[deckbox.Finish]string{
+	deckbox.FinishNonfoil: "Normal",
+	deckbox.FinishFoil:    "Foil",
+	deckbox.FinishEtched:  "Etched",
+	deckbox.FinishGlossy:  "Normal",
+}
+
+/*
+Archidekt writes a CSV for Archidekt's collection importer, the columns are named so they can be matched
+up in its column mapping step
+*/
+type Archidekt struct{}
+
+func (Archidekt) Name() string {
+	return "archidekt"
+}
+
+func (Archidekt) Extension() string {
+	return "archidekt.csv"
+}
+
+func (Archidekt) Export(cards []deckbox.SelectedCard, store data.Store, w io.Writer) error {
+	resolved, err := deckbox.ResolveCards(cards, store)
+	if err != nil {
+		return err
+	}
+
+	out := make([][]string, 1, len(cards)+1)
+
+	out[0] = []string{"Quantity", "Name", "Finish", "Condition", "Language", "Purchase Price", "Edition Code", "Collector Number", "Scryfall ID"}
+
+	for i, sCard := range cards {
+		card := resolved[i]
+
+		condition := archidektConditions[sCard.Condition]
+		if condition == "" {
+			condition = "NM"
+		}
+
+		language := sCard.Language
+		if language == "" {
+			language = "English"
+		}
+
+		out = append(out, []string{
+			fmt.Sprint(sCard.Quantity),
+			card.Name,
+			archidektFinishes[sCard.Finish],
+			condition,
+			language,
+			sCard.MyPrice,
+			card.Set,
+			card.CollectorNumber,
+			card.Id,
+		})
+	}
+
+	err = csv.NewWriter(w).WriteAll(out)
+	if err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"mtg-bulk-input/internal/data"
+	"mtg-bulk-input/internal/deckbox"
+	"sort"
+	"strings"
+)
+
+/*
+Exporter writes selected cards out in the format of a collection site or client.
+Exporters should return a deckbox.ExportError (see deckbox.ResolveCards) when cards can't be found.
+*/
+type Exporter interface {
+	// Name is used to select the exporter, e.g. with the -format flag
+	Name() string
+
+	// Extension of the exported file, without the leading '.'. Formats other than Deckbox include their name
+	// (e.g. "moxfield.csv") so exporting in several formats doesn't overwrite the other exports.
+	Extension() string
+
+	Export(cards []deckbox.SelectedCard, store data.Store, w io.Writer) error
+}
+
+var registry = make(map[string]Exporter)
+
+/*
+The Deckbox exporter isn't registered here as it needs the user's edition overrides, register
+deckbox.NewExporter once they're loaded
+*/
+func init() {
+	Register(Moxfield{})
+	Register(Archidekt{})
+	Register(ManaBox{})
+	Register(TcgplayerMassEntry{})
+	Register(ArenaDeck{})
+}
+
+/*
+Register makes an exporter available through Get, registering a name twice replaces the first exporter
+*/
+func Register(e Exporter) {
+	registry[e.Name()] = e
+}
+
+func Get(name string) (Exporter, bool) {
+	e, ok := registry[name]
+	return e, ok
+}
+
+/*
+Names lists every registered exporter, sorted
+*/
+func Names() []string {
+	out := make([]string, 0, len(registry))
+
+	for name := range registry {
+		out = append(out, name)
+	}
+
+	sort.Strings(out)
+
+	return out
+}
+
+/*
+WriteFile exports the cards next to the session file at sessionPath, with the exporter's extension in place
+of '.json'. The output is written to a temporary file which is only renamed into place once the export has
+succeeded, so a failed export never leaves a truncated or mixed up file behind. The path written is returned.
+*/
+func WriteFile(sessionPath string, e Exporter, cards []deckbox.SelectedCard, store data.Store) (string, error) {
+	p := fmt.Sprintf("%s.%s", strings.TrimSuffix(sessionPath, ".json"), e.Extension())
+
+	err := data.WriteFileAtomic(p, 0644, func(w io.Writer) error {
+		return e.Export(cards, store, w)
+	})
+
+	return p, err
+}
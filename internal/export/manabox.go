@@ -0,0 +1,102 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"mtg-bulk-input/internal/data"
+	"mtg-bulk-input/internal/deckbox"
+)
+
+// Deckbox condition -> ManaBox condition
+var manaBoxConditions = map[string]string{
+	"Mint":                  "mint",
+	"Near Mint":             "near_mint",
+	"Good (Lightly Played)": "light_played",
+	"Played":                "played",
+	"Heavily Played":        "poor",
+	"Poor":                  "poor",
+}
+
+// Deckbox language -> scryfall language code, which is what ManaBox uses
+var languageCodes = map[string]string{
+	"English":             "en",
+	"French":              "fr",
+	"German":              "de",
+	"Italian":             "it",
+	"Japanese":            "ja",
+	"Korean":              "ko",
+	"Portuguese":          "pt",
+	"Russian":             "ru",
+	"Simplified Chinese":  "zhs",
+	"Spanish":             "es",
+	"Traditional Chinese": "zht",
+}
+
+// Finish -> ManaBox foil value
+var manaBoxFinishes = map[deckbox.Finish]string{
+	deckbox.FinishNonfoil: "normal",
+	deckbox.FinishFoil:    "foil",
+	deckbox.FinishEtched:  "etched",
+	deckbox.FinishGlossy:  "normal",
+}
+
+/*
+ManaBox writes a ManaBox collection CSV
+*/
+type ManaBox struct{}
+
+func (ManaBox) Name() string {
+	return "manabox"
+}
+
+func (ManaBox) Extension() string {
+	return "manabox.csv"
+}
+
+func (ManaBox) Export(cards []deckbox.SelectedCard, store data.Store, w io.Writer) error {
+	resolved, err := deckbox.ResolveCards(cards, store)
+	if err != nil {
+		return err
+	}
+
+	out := make([][]string, 1, len(cards)+1)
+
+	out[0] = []string{"Name", "Set code", "Set name", "Collector number", "Foil", "Quantity", "Scryfall ID", "Purchase price", "Misprint", "Altered", "Condition", "Language"}
+
+	for i, sCard := range cards {
+		card := resolved[i]
+
+		condition := manaBoxConditions[sCard.Condition]
+		if condition == "" {
+			condition = "near_mint"
+		}
+
+		language := languageCodes[sCard.Language]
+		if language == "" {
+			language = "en"
+		}
+
+		out = append(out, []string{
+			card.Name,
+			card.Set,
+			card.SetName,
+			card.CollectorNumber,
+			manaBoxFinishes[sCard.Finish],
+			fmt.Sprint(sCard.Quantity),
+			card.Id,
+			sCard.MyPrice,
+			fmt.Sprint(sCard.Misprint),
+			fmt.Sprint(sCard.AlteredArt),
+			condition,
+			language,
+		})
+	}
+
+	err = csv.NewWriter(w).WriteAll(out)
+	if err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	return nil
+}
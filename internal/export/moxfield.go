@@ -0,0 +1,82 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"mtg-bulk-input/internal/data"
+	"mtg-bulk-input/internal/deckbox"
+)
+
+// Deckbox condition -> Moxfield condition
+var moxfieldConditions = map[string]string{
+	"Mint":                  "Mint",
+	"Near Mint":             "Near Mint",
+	"Good (Lightly Played)": "Lightly Played",
+	"Played":                "Moderately Played",
+	"Heavily Played":        "Heavily Played",
+	"Poor":                  "Damaged",
+}
+
+/*
+Moxfield writes a Moxfield collection import CSV
+*/
+type Moxfield struct{}
+
+func (Moxfield) Name() string {
+	return "moxfield"
+}
+
+func (Moxfield) Extension() string {
+	return "moxfield.csv"
+}
+
+func (Moxfield) Export(cards []deckbox.SelectedCard, store data.Store, w io.Writer) error {
+	resolved, err := deckbox.ResolveCards(cards, store)
+	if err != nil {
+		return err
+	}
+
+	out := make([][]string, 1, len(cards)+1)
+
+	out[0] = []string{"Count", "Tradelist Count", "Name", "Edition", "Condition", "Language", "Foil", "Collector Number", "Alter", "Purchase Price"}
+
+	for i, sCard := range cards {
+		card := resolved[i]
+
+		condition := moxfieldConditions[sCard.Condition]
+		if condition == "" {
+			condition = "Near Mint"
+		}
+
+		language := sCard.Language
+		if language == "" {
+			language = "English"
+		}
+
+		foil := ""
+		if sCard.Finish == deckbox.FinishFoil || sCard.Finish == deckbox.FinishEtched {
+			foil = string(sCard.Finish)
+		}
+
+		out = append(out, []string{
+			fmt.Sprint(sCard.Quantity),
+			fmt.Sprint(sCard.TradelistCount),
+			card.Name,
+			card.Set,
+			condition,
+			language,
+			foil,
+			card.CollectorNumber,
+			fmt.Sprint(sCard.AlteredArt),
+			sCard.MyPrice,
+		})
+	}
+
+	err = csv.NewWriter(w).WriteAll(out)
+	if err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	return nil
+}
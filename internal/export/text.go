@@ -0,0 +1,91 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"mtg-bulk-input/internal/data"
+	"mtg-bulk-input/internal/deckbox"
+	"strings"
+)
+
+/*
+TcgplayerMassEntry writes lines for TCGplayer's mass entry box, e.g. "4 Lightning Bolt [M10]"
+*/
+type TcgplayerMassEntry struct{}
+
+func (TcgplayerMassEntry) Name() string {
+	return "tcgplayer"
+}
+
+func (TcgplayerMassEntry) Extension() string {
+	return "tcgplayer.txt"
+}
+
+func (TcgplayerMassEntry) Export(cards []deckbox.SelectedCard, store data.Store, w io.Writer) error {
+	resolved, err := deckbox.ResolveCards(cards, store)
+	if err != nil {
+		return err
+	}
+
+	for i, sCard := range cards {
+		card := resolved[i]
+
+		_, err = fmt.Fprintf(w, "%d %s [%s]\n", sCard.Quantity, card.Name, strings.ToUpper(card.Set))
+		if err != nil {
+			return fmt.Errorf("failed to write line: %w", err)
+		}
+	}
+
+	return nil
+}
+
+/*
+ArenaDeck writes an MTG Arena style deck list, e.g. "4 Lightning Bolt (M10) 146".
+Arena doesn't track finishes so the same printing in different finishes is combined onto one line.
+*/
+type ArenaDeck struct{}
+
+func (ArenaDeck) Name() string {
+	return "arena"
+}
+
+func (ArenaDeck) Extension() string {
+	return "arena.txt"
+}
+
+func (ArenaDeck) Export(cards []deckbox.SelectedCard, store data.Store, w io.Writer) error {
+	resolved, err := deckbox.ResolveCards(cards, store)
+	if err != nil {
+		return err
+	}
+
+	lines := make([]string, 0, len(cards))
+	counts := make(map[string]int)
+
+	for i, sCard := range cards {
+		card := resolved[i]
+
+		// Arena names double faced cards by their front face, the same as Deckbox
+		line := fmt.Sprintf("%s (%s) %s", deckbox.CardName(card), strings.ToUpper(card.Set), card.CollectorNumber)
+
+		if _, ok := counts[line]; !ok {
+			lines = append(lines, line)
+		}
+
+		counts[line] += sCard.Quantity
+	}
+
+	_, err = fmt.Fprintln(w, "Deck")
+	if err != nil {
+		return fmt.Errorf("failed to write line: %w", err)
+	}
+
+	for _, line := range lines {
+		_, err = fmt.Fprintf(w, "%d %s\n", counts[line], line)
+		if err != nil {
+			return fmt.Errorf("failed to write line: %w", err)
+		}
+	}
+
+	return nil
+}
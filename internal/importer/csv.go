@@ -0,0 +1,265 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mtg-bulk-input/internal/deckbox"
+	"strconv"
+	"strings"
+)
+
+/*
+hasColumns reports whether the first line is a CSV header containing every column
+*/
+func hasColumns(lines []string, columns ...string) bool {
+	if len(lines) == 0 {
+		return false
+	}
+
+	header, err := csv.NewReader(strings.NewReader(lines[0])).Read()
+	if err != nil {
+		return false
+	}
+
+	present := make(map[string]bool, len(header))
+	for _, h := range header {
+		present[strings.TrimSpace(h)] = true
+	}
+
+	for _, c := range columns {
+		if !present[c] {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+parseCsv reads a CSV with a header row, calling parseRow with a getter for each row's columns
+*/
+func parseCsv(text string, ctx Context, parseRow func(get func(column string) string) (Entry, error)) []Result {
+	cr := csv.NewReader(strings.NewReader(text))
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return []Result{{Line: 1, Text: firstLine(text), Err: fmt.Errorf("failed to read CSV header: %w", err)}}
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[strings.TrimSpace(h)] = i
+	}
+
+	lines := splitLines(text)
+	out := []Result{{Line: 1, Text: lines[0], Skipped: true}}
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		// FieldPos can only be used after a successful read
+		line := 0
+		if err == nil {
+			line, _ = cr.FieldPos(0)
+		} else if pe := (*csv.ParseError)(nil); errors.As(err, &pe) {
+			line = pe.StartLine
+		}
+
+		res := Result{Line: line}
+		if line > 0 && line <= len(lines) {
+			res.Text = lines[line-1]
+		}
+
+		if err != nil {
+			// The rest of the file can't be trusted once the CSV itself is broken
+			res.Err = fmt.Errorf("failed to read CSV: %w", err)
+			out = append(out, res)
+			break
+		}
+
+		get := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+
+			return strings.TrimSpace(record[i])
+		}
+
+		entry, err := parseRow(get)
+		res.Entry = entry
+
+		if err != nil {
+			res.Err = err
+		} else {
+			res.resolve(ctx)
+		}
+
+		out = append(out, res)
+	}
+
+	return out
+}
+
+func firstLine(text string) string {
+	return splitLines(text)[0]
+}
+
+func parseCount(v string) (int, error) {
+	count, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity '%s'", v)
+	}
+
+	return count, nil
+}
+
+/*
+DeckboxCsv reads Deckbox inventory exports, see deckbox.ImportRows
+*/
+type DeckboxCsv struct{}
+
+func (DeckboxCsv) Name() string {
+	return "deckbox"
+}
+
+func (DeckboxCsv) Detect(lines []string) float64 {
+	if hasColumns(lines, "Count", "Name", "Edition") {
+		return 1
+	}
+
+	return 0
+}
+
+func (DeckboxCsv) Parse(text string, ctx Context) []Result {
+	rows, err := deckbox.ImportRows(strings.NewReader(text), ctx.Store, ctx.Editions)
+	if err != nil {
+		return []Result{{Line: 1, Text: firstLine(text), Err: err}}
+	}
+
+	lines := splitLines(text)
+	out := []Result{{Line: 1, Text: lines[0], Skipped: true}}
+
+	for _, row := range rows {
+		row := row
+
+		res := Result{
+			Line: row.Line,
+			Entry: Entry{
+				Count:  row.Card.Quantity,
+				Name:   row.Name,
+				Set:    row.Card.Set,
+				Number: row.Card.Number,
+				Finish: row.Card.Finish,
+			},
+			Err: row.Err,
+		}
+
+		if row.Line > 0 && row.Line <= len(lines) {
+			res.Text = lines[row.Line-1]
+		}
+
+		if row.Err == nil {
+			res.Card = &row.Card
+		}
+
+		out = append(out, res)
+	}
+
+	return out
+}
+
+/*
+ManaBoxCsv reads ManaBox collection exports
+*/
+type ManaBoxCsv struct{}
+
+func (ManaBoxCsv) Name() string {
+	return "manabox"
+}
+
+func (ManaBoxCsv) Detect(lines []string) float64 {
+	if hasColumns(lines, "Name", "Set code", "Collector number", "Quantity") {
+		return 1
+	}
+
+	return 0
+}
+
+func (ManaBoxCsv) Parse(text string, ctx Context) []Result {
+	return parseCsv(text, ctx, func(get func(column string) string) (Entry, error) {
+		count, err := parseCount(get("Quantity"))
+		if err != nil {
+			return Entry{}, err
+		}
+
+		var finish deckbox.Finish
+
+		switch get("Foil") {
+		case "", "normal":
+			finish = deckbox.FinishNonfoil
+		case "foil":
+			finish = deckbox.FinishFoil
+		case "etched":
+			finish = deckbox.FinishEtched
+		default:
+			return Entry{}, fmt.Errorf("unknown foil value '%s'", get("Foil"))
+		}
+
+		return Entry{
+			Count:  count,
+			Name:   get("Name"),
+			Set:    get("Set code"),
+			Number: get("Collector number"),
+			Finish: finish,
+		}, nil
+	})
+}
+
+/*
+MtgoCsv reads Magic Online collection CSV exports
+*/
+type MtgoCsv struct{}
+
+func (MtgoCsv) Name() string {
+	return "mtgo"
+}
+
+func (MtgoCsv) Detect(lines []string) float64 {
+	if hasColumns(lines, "Card Name", "Quantity", "Set", "Collector #") {
+		return 1
+	}
+
+	return 0
+}
+
+func (MtgoCsv) Parse(text string, ctx Context) []Result {
+	return parseCsv(text, ctx, func(get func(column string) string) (Entry, error) {
+		count, err := parseCount(get("Quantity"))
+		if err != nil {
+			return Entry{}, err
+		}
+
+		finish := deckbox.FinishNonfoil
+		if strings.EqualFold(get("Premium"), "yes") {
+			finish = deckbox.FinishFoil
+		}
+
+		// Numbers are written as "146/249"
+		number, _, _ := strings.Cut(get("Collector #"), "/")
+
+		return Entry{
+			Count:  count,
+			Name:   get("Card Name"),
+			Set:    get("Set"),
+			Number: number,
+			Finish: finish,
+		}, nil
+	})
+}
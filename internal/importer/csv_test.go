@@ -0,0 +1,29 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+TestParseCsvMalformedField checks a CSV error in a row's first field is reported on its line rather than panicking
+*/
+func TestParseCsvMalformedField(t *testing.T) {
+	text := "Name,Set code,Collector number,Quantity,Foil\n\"Bolt\"x,m10,146,1,foil"
+
+	results := ManaBoxCsv{}.Parse(text, Context{})
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want the header and the bad row", len(results))
+	}
+
+	res := results[1]
+
+	if res.Line != 2 || res.Text != `"Bolt"x,m10,146,1,foil` {
+		t.Errorf("got line %d %q, want line 2", res.Line, res.Text)
+	}
+
+	if res.Err == nil || !strings.Contains(res.Err.Error(), "failed to read CSV") {
+		t.Errorf("got error %v, want a CSV read error", res.Err)
+	}
+}
@@ -0,0 +1,339 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"mtg-bulk-input/internal/data"
+	"mtg-bulk-input/internal/deckbox"
+	"mtg-bulk-input/internal/scryfall"
+	"strings"
+)
+
+/*
+Entry is a card as it was written in an import, before it has been matched to a printing
+*/
+type Entry struct {
+	Count int
+	Name  string
+
+	// Scryfall set code and collector number, either can be empty if the format doesn't include them
+	Set    string
+	Number string
+
+	Finish deckbox.Finish
+}
+
+/*
+CardFinish is the entry's finish, entries without one are nonfoil
+*/
+func (e Entry) CardFinish() deckbox.Finish {
+	if e.Finish == "" {
+		return deckbox.FinishNonfoil
+	}
+
+	return e.Finish
+}
+
+/*
+Result is the outcome of importing one line. Skipped lines (blank lines, headers, section names) have
+neither a Card nor an Err.
+*/
+type Result struct {
+	// 1-based line in the imported text
+	Line int
+	Text string
+
+	Entry Entry
+
+	Card    *deckbox.SelectedCard
+	Err     error
+	Skipped bool
+
+	// Printings the entry could be when it was matched by name, newest first. Any of them can be picked in place
+	// of the one chosen by the NameResolution.
+	Candidates []scryfall.Card
+}
+
+func (r Result) Resolved() bool {
+	return r.Card != nil
+}
+
+/*
+Ambiguous reports whether the entry matched several printings and one has to be picked, see StrategyPrompt
+*/
+func (r Result) Ambiguous() bool {
+	return errors.Is(r.Err, ErrAmbiguous)
+}
+
+/*
+Pick resolves the result to one of its candidates
+*/
+func (r *Result) Pick(card scryfall.Card) {
+	r.Card = &deckbox.SelectedCard{
+		Set:      card.Set,
+		Quantity: r.Entry.Count,
+		Number:   card.CollectorNumber,
+		Finish:   r.Entry.CardFinish(),
+	}
+	r.Err = nil
+}
+
+/*
+resolve matches the result's entry to a printing, setting Card or Err
+*/
+func (r *Result) resolve(ctx Context) {
+	sCard, candidates, err := Resolve(r.Entry, ctx)
+
+	r.Candidates = candidates
+
+	if err != nil {
+		r.Err = err
+	} else {
+		r.Card = &sCard
+	}
+}
+
+/*
+Context is what parsers need to resolve entries into printings
+*/
+type Context struct {
+	Store    data.Store
+	Editions deckbox.EditionMapper
+
+	// How entries without a collector number are matched to a printing
+	Names NameResolution
+}
+
+/*
+Parser reads one import format
+*/
+type Parser interface {
+	Name() string
+
+	// Detect scores how likely it is that the lines are in this format, from 0 (not at all) to 1
+	Detect(lines []string) float64
+
+	// Parse returns a result for every line of text, it never stops at a bad line
+	Parse(text string, ctx Context) []Result
+}
+
+// Parsers in order of preference when their Detect scores tie
+var parsers = []Parser{
+	DeckboxCsv{},
+	ManaBoxCsv{},
+	MtgoCsv{},
+	Archidekt{},
+	Moxfield{},
+	Arena{},
+	Plain{},
+}
+
+/*
+Get finds a parser by name, for forcing a format when Detect guesses wrong
+*/
+func Get(name string) (Parser, bool) {
+	for _, p := range parsers {
+		if p.Name() == name {
+			return p, true
+		}
+	}
+
+	return nil, false
+}
+
+/*
+Names lists every parser, in order of preference
+*/
+func Names() []string {
+	out := make([]string, 0, len(parsers))
+
+	for _, p := range parsers {
+		out = append(out, p.Name())
+	}
+
+	return out
+}
+
+/*
+Detect picks the parser most likely to understand the text
+*/
+func Detect(text string) Parser {
+	lines := nonBlankLines(text)
+
+	var best Parser = Plain{}
+	bestScore := 0.0
+
+	for _, p := range parsers {
+		score := p.Detect(lines)
+		if score > bestScore {
+			best = p
+			bestScore = score
+		}
+	}
+
+	return best
+}
+
+func splitLines(text string) []string {
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
+
+func nonBlankLines(text string) []string {
+	out := make([]string, 0)
+
+	for _, l := range splitLines(text) {
+		if strings.TrimSpace(l) != "" {
+			out = append(out, l)
+		}
+	}
+
+	return out
+}
+
+/*
+parseLines runs parseLine over each line of text, resolving the entries it returns.
+parseLine returns skip for lines that don't hold a card.
+*/
+func parseLines(text string, ctx Context, parseLine func(line string) (entry Entry, skip bool, err error)) []Result {
+	lines := splitLines(text)
+	out := make([]Result, 0, len(lines))
+
+	for i, line := range lines {
+		res := Result{
+			Line: i + 1,
+			Text: line,
+		}
+
+		if strings.TrimSpace(line) == "" {
+			res.Skipped = true
+			out = append(out, res)
+			continue
+		}
+
+		entry, skip, err := parseLine(strings.TrimSpace(line))
+		res.Entry = entry
+
+		switch {
+		case skip:
+			res.Skipped = true
+		case err != nil:
+			res.Err = err
+		default:
+			res.resolve(ctx)
+		}
+
+		out = append(out, res)
+	}
+
+	return out
+}
+
+/*
+Resolve matches an entry to a printing in the store. Entries with a set and collector number are looked up directly,
+anything else by name, using ctx.Names to choose between printings. The candidates are returned when the entry was
+matched by name.
+*/
+func Resolve(entry Entry, ctx Context) (deckbox.SelectedCard, []scryfall.Card, error) {
+	if entry.Count < 1 {
+		return deckbox.SelectedCard{}, nil, fmt.Errorf("invalid count %d", entry.Count)
+	}
+
+	finish := entry.CardFinish()
+
+	var card scryfall.Card
+	var candidates []scryfall.Card
+	var err error
+
+	if entry.Set != "" && entry.Number != "" {
+		card, err = resolveBySet(entry, ctx.Store)
+	} else {
+		candidates, err = printings(entry, finish, ctx.Store)
+		if err == nil {
+			card, err = ctx.Names.choose(entry, finish, candidates)
+		}
+	}
+
+	if err != nil {
+		return deckbox.SelectedCard{}, candidates, err
+	}
+
+	if !finish.AvailableFor(card) {
+		return deckbox.SelectedCard{}, candidates, fmt.Errorf("%s - %s was not printed in finish '%s'", card.Set, card.CollectorNumber, finish)
+	}
+
+	return deckbox.SelectedCard{
+		Set:      card.Set,
+		Quantity: entry.Count,
+		Number:   card.CollectorNumber,
+		Finish:   finish,
+	}, candidates, nil
+}
+
+func resolveBySet(entry Entry, store data.Store) (scryfall.Card, error) {
+	cards, ok := store.SetCards[strings.ToLower(entry.Set)]
+	if !ok {
+		return scryfall.Card{}, fmt.Errorf("unknown set '%s'", entry.Set)
+	}
+
+	card, ok := deckbox.LookupNumber(cards, entry.Number)
+	if !ok {
+		return scryfall.Card{}, fmt.Errorf("set '%s' has no card numbered '%s'", entry.Set, entry.Number)
+	}
+
+	if entry.Name != "" && !deckbox.NamesMatch(card, entry.Name) {
+		return scryfall.Card{}, fmt.Errorf("%s - %s is '%s', not '%s'", card.Set, card.CollectorNumber, card.Name, entry.Name)
+	}
+
+	return card, nil
+}
+
+/*
+printings finds the paper printings of the entry's card in its finish, limited to the entry's set if it has one.
+They're sorted newest first.
+*/
+func printings(entry Entry, finish deckbox.Finish, store data.Store) ([]scryfall.Card, error) {
+	if entry.Name == "" {
+		return nil, fmt.Errorf("no name or collector number given")
+	}
+
+	var all []scryfall.Card
+
+	if entry.Set != "" {
+		cards, ok := store.SetCards[strings.ToLower(entry.Set)]
+		if !ok {
+			return nil, fmt.Errorf("unknown set '%s'", entry.Set)
+		}
+
+		for _, card := range cards {
+			if deckbox.NamesMatch(card, entry.Name) {
+				all = append(all, card)
+			}
+		}
+	} else {
+		// Un-set cards and promos are in binders too, tokens and art cards share names with real cards
+		all = store.Index.Printings(entry.Name, data.ExcludedNotLegal)
+	}
+
+	if len(all) == 0 {
+		if entry.Set != "" {
+			return nil, fmt.Errorf("no card named '%s' in set '%s'", entry.Name, entry.Set)
+		}
+		return nil, fmt.Errorf("no card named '%s'", entry.Name)
+	}
+
+	out := make([]scryfall.Card, 0, len(all))
+
+	for _, card := range all {
+		if !card.Digital && finish.AvailableFor(card) {
+			out = append(out, card)
+		}
+	}
+
+	if len(out) == 0 {
+		return nil, fmt.Errorf("'%s' was never printed on paper in finish '%s'", entry.Name, finish)
+	}
+
+	sortNewestFirst(out)
+
+	return out, nil
+}
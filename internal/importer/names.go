@@ -0,0 +1,130 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"mtg-bulk-input/internal/deckbox"
+	"mtg-bulk-input/internal/scryfall"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+Strategy decides which printing an entry without a collector number becomes, e.g. "4 Counterspell"
+*/
+type Strategy string
+
+const (
+	// StrategyCheapest picks the printing with the lowest price in the entry's finish
+	StrategyCheapest Strategy = "cheapest"
+	StrategyNewest   Strategy = "newest"
+	StrategyOldest   Strategy = "oldest"
+
+	// StrategyPreferred picks from NameResolution.PreferredSets in order, then falls back to the newest printing
+	StrategyPreferred Strategy = "preferred"
+
+	// StrategyPrompt leaves entries with several printings unresolved with ErrAmbiguous, so one can be picked
+	StrategyPrompt Strategy = "prompt"
+)
+
+var Strategies = []Strategy{
+	StrategyPrompt,
+	StrategyCheapest,
+	StrategyNewest,
+	StrategyOldest,
+	StrategyPreferred,
+}
+
+var ErrAmbiguous = errors.New("pick a printing")
+
+func ParseStrategy(s string) (Strategy, error) {
+	for _, st := range Strategies {
+		if string(st) == s {
+			return st, nil
+		}
+	}
+
+	names := make([]string, 0, len(Strategies))
+	for _, st := range Strategies {
+		names = append(names, string(st))
+	}
+
+	return "", fmt.Errorf("unknown strategy '%s', expected one of: %s", s, strings.Join(names, ", "))
+}
+
+/*
+NameResolution configures how entries are matched to a printing by name
+*/
+type NameResolution struct {
+	// Defaults to StrategyPrompt
+	Strategy Strategy
+
+	// Scryfall set codes, most preferred first, used by StrategyPreferred
+	PreferredSets []string
+}
+
+/*
+choose picks one of candidates, which are sorted newest first
+*/
+func (nr NameResolution) choose(entry Entry, finish deckbox.Finish, candidates []scryfall.Card) (scryfall.Card, error) {
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+
+	switch nr.Strategy {
+	case StrategyCheapest:
+		cheapest := candidates[0]
+		cheapestPrice, cheapestOk := cardPrice(finish, cheapest)
+
+		for _, card := range candidates[1:] {
+			price, ok := cardPrice(finish, card)
+
+			// Unpriced printings are only picked if none have prices, ties go to the newer printing
+			if ok && (!cheapestOk || price < cheapestPrice) {
+				cheapest, cheapestPrice, cheapestOk = card, price, true
+			}
+		}
+
+		return cheapest, nil
+	case StrategyNewest:
+		return candidates[0], nil
+	case StrategyOldest:
+		return candidates[len(candidates)-1], nil
+	case StrategyPreferred:
+		for _, set := range nr.PreferredSets {
+			for _, card := range candidates {
+				if strings.EqualFold(card.Set, set) {
+					return card, nil
+				}
+			}
+		}
+
+		return candidates[0], nil
+	default:
+		return scryfall.Card{}, fmt.Errorf("%d printings of '%s': %w", len(candidates), entry.Name, ErrAmbiguous)
+	}
+}
+
+func cardPrice(finish deckbox.Finish, card scryfall.Card) (float64, bool) {
+	price, err := strconv.ParseFloat(finish.Price(card), 64)
+	return price, err == nil
+}
+
+/*
+sortNewestFirst sorts printings by release date, then set and collector number so the order is stable
+*/
+func sortNewestFirst(cards []scryfall.Card) {
+	sort.Slice(cards, func(i, j int) bool {
+		if cards[i].ReleasedAt != cards[j].ReleasedAt {
+			// Dates are YYYY-MM-DD so they sort as strings
+			return cards[i].ReleasedAt > cards[j].ReleasedAt
+		}
+
+		if cards[i].Set != cards[j].Set {
+			return cards[i].Set < cards[j].Set
+		}
+
+		return cards[i].CollectorNumber < cards[j].CollectorNumber
+	})
+}
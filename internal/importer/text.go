@@ -0,0 +1,226 @@
+package importer
+
+import (
+	"fmt"
+	"mtg-bulk-input/internal/deckbox"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+The grammar shared by the text formats. Set codes are 2-6 letters or digits in any case (e.g. "PLST", "30A", "m10")
+and collector numbers are anything up to the next space (e.g. "123a", "45★", "DOM-123").
+*/
+const (
+	setCodePattern = `([0-9A-Za-z]{2,6})`
+	numberPattern  = `(\S+)`
+)
+
+var (
+	moxfieldLineRegex  = regexp.MustCompile(`^(\d+)\s+(.+?)\s+\(` + setCodePattern + `\)\s+` + numberPattern + `(?:\s+\*([FE])\*)?$`)
+	arenaLineRegex     = regexp.MustCompile(`^(\d+)\s+(.+?)\s+\(` + setCodePattern + `\)\s+` + numberPattern + `$`)
+	archidektLineRegex = regexp.MustCompile(`^(\d+)x\s+(.+?)\s+\(` + setCodePattern + `\)\s+` + numberPattern + `(?:\s+\*([FE])\*)?(?:\s+\[[^\]]*\])?(?:\s+\^[^^]*\^)?$`)
+	plainLineRegex     = regexp.MustCompile(`^(?:(\d+)x?\s+)?(.+?)(?:\s+\(` + setCodePattern + `\))?$`)
+)
+
+// Section headings in Arena exports, they don't hold cards
+var arenaSections = map[string]bool{
+	"deck":       true,
+	"sideboard":  true,
+	"commander":  true,
+	"companion":  true,
+	"maybeboard": true,
+}
+
+/*
+matchFraction is the share of lines that match any of the regular expressions
+*/
+func matchFraction(lines []string, regexes ...*regexp.Regexp) float64 {
+	if len(lines) == 0 {
+		return 0
+	}
+
+	matched := 0
+
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+
+		for _, re := range regexes {
+			if re.MatchString(l) {
+				matched++
+				break
+			}
+		}
+	}
+
+	return float64(matched) / float64(len(lines))
+}
+
+/*
+finishFromMarker maps the *F* / *E* markers used by Moxfield and Archidekt to a finish
+*/
+func finishFromMarker(marker string) deckbox.Finish {
+	switch marker {
+	case "F":
+		return deckbox.FinishFoil
+	case "E":
+		return deckbox.FinishEtched
+	default:
+		return deckbox.FinishNonfoil
+	}
+}
+
+/*
+Moxfield reads Moxfield exports, e.g. "1 Fierce Guardianship (CMM) 694 *F*"
+*/
+type Moxfield struct{}
+
+func (Moxfield) Name() string {
+	return "moxfield"
+}
+
+func (Moxfield) Detect(lines []string) float64 {
+	return matchFraction(lines, moxfieldLineRegex)
+}
+
+func (Moxfield) Parse(text string, ctx Context) []Result {
+	return parseLines(text, ctx, func(line string) (Entry, bool, error) {
+		match := moxfieldLineRegex.FindStringSubmatch(line)
+		if match == nil {
+			return Entry{}, false, fmt.Errorf("expected '<count> <name> (<set>) <number>', optionally followed by *F* or *E*")
+		}
+
+		count, _ := strconv.Atoi(match[1])
+
+		return Entry{
+			Count:  count,
+			Name:   match[2],
+			Set:    match[3],
+			Number: match[4],
+			Finish: finishFromMarker(match[5]),
+		}, false, nil
+	})
+}
+
+/*
+Arena reads MTG Arena deck exports, e.g. "4 Lightning Bolt (M10) 146" under "Deck" / "Sideboard" headings
+*/
+type Arena struct{}
+
+func (Arena) Name() string {
+	return "arena"
+}
+
+func (Arena) Detect(lines []string) float64 {
+	if len(lines) == 0 {
+		return 0
+	}
+
+	matched := 0
+
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+
+		if arenaSections[strings.ToLower(l)] || arenaLineRegex.MatchString(l) {
+			matched++
+		}
+	}
+
+	return float64(matched) / float64(len(lines))
+}
+
+func (Arena) Parse(text string, ctx Context) []Result {
+	return parseLines(text, ctx, func(line string) (Entry, bool, error) {
+		if arenaSections[strings.ToLower(line)] {
+			return Entry{}, true, nil
+		}
+
+		match := arenaLineRegex.FindStringSubmatch(line)
+		if match == nil {
+			return Entry{}, false, fmt.Errorf("expected '<count> <name> (<set>) <number>'")
+		}
+
+		count, _ := strconv.Atoi(match[1])
+
+		return Entry{
+			Count:  count,
+			Name:   match[2],
+			Set:    match[3],
+			Number: match[4],
+		}, false, nil
+	})
+}
+
+/*
+Archidekt reads Archidekt text exports, e.g. "1x Lightning Bolt (m10) 146 *F* [Burn]"
+*/
+type Archidekt struct{}
+
+func (Archidekt) Name() string {
+	return "archidekt"
+}
+
+func (Archidekt) Detect(lines []string) float64 {
+	return matchFraction(lines, archidektLineRegex)
+}
+
+func (Archidekt) Parse(text string, ctx Context) []Result {
+	return parseLines(text, ctx, func(line string) (Entry, bool, error) {
+		match := archidektLineRegex.FindStringSubmatch(line)
+		if match == nil {
+			return Entry{}, false, fmt.Errorf("expected '<count>x <name> (<set>) <number>'")
+		}
+
+		count, _ := strconv.Atoi(match[1])
+
+		return Entry{
+			Count:  count,
+			Name:   match[2],
+			Set:    match[3],
+			Number: match[4],
+			Finish: finishFromMarker(match[5]),
+		}, false, nil
+	})
+}
+
+/*
+Plain reads plain decklists, e.g. "4 Lightning Bolt", "Lightning Bolt" or "4x Lightning Bolt (M10)", which
+don't give a collector number. The printing is chosen by Context.Names.
+*/
+type Plain struct{}
+
+func (Plain) Name() string {
+	return "plain"
+}
+
+func (Plain) Detect(lines []string) float64 {
+	// Anything can be read as a plain list, so only pick it if nothing else fits better
+	if len(lines) == 0 {
+		return 0
+	}
+
+	return 0.1
+}
+
+func (Plain) Parse(text string, ctx Context) []Result {
+	return parseLines(text, ctx, func(line string) (Entry, bool, error) {
+		// Comments and section headings
+		if strings.HasPrefix(line, "//") || strings.HasPrefix(line, "#") || arenaSections[strings.ToLower(strings.TrimSuffix(line, ":"))] {
+			return Entry{}, true, nil
+		}
+
+		match := plainLineRegex.FindStringSubmatch(line)
+
+		count := 1
+		if match[1] != "" {
+			count, _ = strconv.Atoi(match[1])
+		}
+
+		return Entry{
+			Count: count,
+			Name:  strings.TrimSpace(match[2]),
+			Set:   match[3],
+		}, false, nil
+	})
+}
@@ -0,0 +1,136 @@
+package query
+
+import (
+	"fmt"
+	"mtg-bulk-input/internal/scryfall"
+	"strings"
+)
+
+var colorNames = map[string]string{
+	"white": "W",
+	"blue":  "U",
+	"black": "B",
+	"red":   "R",
+	"green": "G",
+}
+
+/*
+colorTerm compares a card's colors, or its color identity if identity is set, to a set of colors such as "rg", "red",
+"colorless" (or "c") and "multicolor" (or "m"). As on Scryfall, c: finds cards with at least those colors and id:
+finds cards that fit in that identity (e.g. for a commander deck).
+*/
+func colorTerm(identity bool) func(op, value string) (node, error) {
+	return func(op, value string) (node, error) {
+		cardColors := func(card scryfall.Card) map[string]bool {
+			out := make(map[string]bool)
+
+			list := card.Colors
+			if identity {
+				list = card.ColorIdentity
+			}
+
+			for _, c := range list {
+				out[c] = true
+			}
+
+			// Cards with several faces have their colors on the faces
+			if !identity && len(list) == 0 {
+				for _, face := range card.CardFaces {
+					for _, c := range face.Colors {
+						out[c] = true
+					}
+				}
+			}
+
+			return out
+		}
+
+		switch strings.ToLower(value) {
+		case "c", "colorless":
+			return matchFunc(func(card scryfall.Card) bool {
+				return (len(cardColors(card)) == 0) != (op == "!=")
+			}), nil
+		case "m", "multicolor":
+			return matchFunc(func(card scryfall.Card) bool {
+				return (len(cardColors(card)) > 1) != (op == "!=")
+			}), nil
+		}
+
+		want, err := parseColors(value)
+		if err != nil {
+			return nil, err
+		}
+
+		if op == ":" {
+			if identity {
+				op = "<="
+			} else {
+				op = ">="
+			}
+		}
+
+		return matchFunc(func(card scryfall.Card) bool {
+			return compareColors(cardColors(card), want, op)
+		}), nil
+	}
+}
+
+func parseColors(value string) (map[string]bool, error) {
+	out := make(map[string]bool)
+
+	if c, ok := colorNames[strings.ToLower(value)]; ok {
+		out[c] = true
+		return out, nil
+	}
+
+	for _, r := range strings.ToUpper(value) {
+		switch r {
+		case 'W', 'U', 'B', 'R', 'G':
+			out[string(r)] = true
+		default:
+			return nil, fmt.Errorf("unknown color '%c', expected a mix of w, u, b, r and g", r)
+		}
+	}
+
+	return out, nil
+}
+
+/*
+compareColors compares two sets of colors like numbers, ">=" being a superset and "<=" a subset
+*/
+func compareColors(have, want map[string]bool, op string) bool {
+	superset := true
+	for c := range want {
+		if !have[c] {
+			superset = false
+			break
+		}
+	}
+
+	subset := true
+	for c := range have {
+		if !want[c] {
+			subset = false
+			break
+		}
+	}
+
+	equal := superset && subset
+
+	switch op {
+	case "=":
+		return equal
+	case "!=":
+		return !equal
+	case ">=":
+		return superset
+	case ">":
+		return superset && !equal
+	case "<=":
+		return subset
+	case "<":
+		return subset && !equal
+	default:
+		return false
+	}
+}
@@ -0,0 +1,344 @@
+package query
+
+import (
+	"fmt"
+	"mtg-bulk-input/internal/deckbox"
+	"mtg-bulk-input/internal/scryfall"
+	"strconv"
+	"strings"
+)
+
+// Keyed: keyword and its aliases, as in Scryfall's syntax
+var keywords = map[string]func(op, value string) (node, error){
+	"t":         typeTerm,
+	"type":      typeTerm,
+	"o":         oracleTerm,
+	"oracle":    oracleTerm,
+	"c":         colorTerm(false),
+	"color":     colorTerm(false),
+	"id":        colorTerm(true),
+	"identity":  colorTerm(true),
+	"mv":        manaValueTerm,
+	"cmc":       manaValueTerm,
+	"manavalue": manaValueTerm,
+	"r":         rarityTerm,
+	"rarity":    rarityTerm,
+	"s":         setTerm,
+	"set":       setTerm,
+	"e":         setTerm,
+	"edition":   setTerm,
+	"is":        isTerm,
+	"usd":       priceTerm(usdPrice),
+	"eur":       priceTerm(eurPrice),
+	"tix":       priceTerm(tixPrice),
+	"a":         artistTerm,
+	"artist":    artistTerm,
+	"f":         formatTerm,
+	"format":    formatTerm,
+	"legal":     formatTerm,
+	"name":      nameKeywordTerm,
+}
+
+func requireColon(op string) error {
+	if op != ":" && op != "=" {
+		return fmt.Errorf("only ':' can be used here")
+	}
+
+	return nil
+}
+
+/*
+containsTerm matches cards where any of the fields contains value, ignoring case
+*/
+func containsTerm(op, value string, fields func(card scryfall.Card) []string) (node, error) {
+	if err := requireColon(op); err != nil {
+		return nil, err
+	}
+
+	value = strings.ToLower(value)
+
+	return matchFunc(func(card scryfall.Card) bool {
+		for _, f := range fields(card) {
+			if strings.Contains(strings.ToLower(f), value) {
+				return true
+			}
+		}
+
+		return false
+	}), nil
+}
+
+func typeTerm(op, value string) (node, error) {
+	return containsTerm(op, value, func(card scryfall.Card) []string {
+		out := []string{card.TypeLine}
+		for _, face := range card.CardFaces {
+			out = append(out, face.TypeLine)
+		}
+
+		return out
+	})
+}
+
+func oracleTerm(op, value string) (node, error) {
+	if err := requireColon(op); err != nil {
+		return nil, err
+	}
+
+	value = strings.ToLower(value)
+
+	return matchFunc(func(card scryfall.Card) bool {
+		texts := []string{card.OracleText}
+		names := []string{card.Name}
+
+		for _, face := range card.CardFaces {
+			texts = append(texts, face.OracleText)
+			names = append(names, face.Name)
+		}
+
+		for i, text := range texts {
+			// '~' stands for the card's own name, as on Scryfall
+			v := value
+			if i < len(names) {
+				v = strings.ReplaceAll(value, "~", strings.ToLower(names[i]))
+			}
+
+			if strings.Contains(strings.ToLower(text), v) {
+				return true
+			}
+		}
+
+		return false
+	}), nil
+}
+
+func artistTerm(op, value string) (node, error) {
+	return containsTerm(op, value, func(card scryfall.Card) []string {
+		out := []string{card.Artist}
+		for _, face := range card.CardFaces {
+			out = append(out, face.Artist)
+		}
+
+		return out
+	})
+}
+
+func nameKeywordTerm(op, value string) (node, error) {
+	if err := requireColon(op); err != nil {
+		return nil, err
+	}
+
+	return nameTerm(value)
+}
+
+func setTerm(op, value string) (node, error) {
+	if op != ":" && op != "=" && op != "!=" {
+		return nil, fmt.Errorf("only ':' or '!=' can be used here")
+	}
+
+	value = strings.ToLower(value)
+
+	return matchFunc(func(card scryfall.Card) bool {
+		return (card.Set == value) != (op == "!=")
+	}), nil
+}
+
+/*
+compare applies a comparison operator, ':' is the same as '='
+*/
+func compare(a, b float64, op string) bool {
+	switch op {
+	case ":", "=":
+		return a == b
+	case "!=":
+		return a != b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	default:
+		return false
+	}
+}
+
+func manaValueTerm(op, value string) (node, error) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not a number", value)
+	}
+
+	return matchFunc(func(card scryfall.Card) bool {
+		return compare(card.Cmc, v, op)
+	}), nil
+}
+
+var rarities = map[string]int{
+	"common":   0,
+	"uncommon": 1,
+	"rare":     2,
+	"special":  3,
+	"mythic":   4,
+	"bonus":    5,
+}
+
+var rarityAbbreviations = map[string]string{
+	"c": "common",
+	"u": "uncommon",
+	"r": "rare",
+	"s": "special",
+	"m": "mythic",
+	"b": "bonus",
+}
+
+func rarityTerm(op, value string) (node, error) {
+	value = strings.ToLower(value)
+
+	if full, ok := rarityAbbreviations[value]; ok {
+		value = full
+	}
+
+	v, ok := rarities[value]
+	if !ok {
+		return nil, fmt.Errorf("unknown rarity '%s'", value)
+	}
+
+	return matchFunc(func(card scryfall.Card) bool {
+		r, ok := rarities[card.Rarity]
+		return ok && compare(float64(r), float64(v), op)
+	}), nil
+}
+
+func usdPrice(card scryfall.Card) string {
+	switch {
+	case card.Prices.Usd != "":
+		return card.Prices.Usd
+	case card.Prices.UsdFoil != "":
+		return card.Prices.UsdFoil
+	default:
+		return card.Prices.UsdEtched
+	}
+}
+
+func eurPrice(card scryfall.Card) string {
+	if card.Prices.Eur != "" {
+		return card.Prices.Eur
+	}
+
+	return card.Prices.EurFoil
+}
+
+func tixPrice(card scryfall.Card) string {
+	return card.Prices.Tix
+}
+
+/*
+priceTerm compares a price, using the non-foil price if there is one and the foil price otherwise.
+Cards without a price never match.
+*/
+func priceTerm(price func(card scryfall.Card) string) func(op, value string) (node, error) {
+	return func(op, value string) (node, error) {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a price", value)
+		}
+
+		return matchFunc(func(card scryfall.Card) bool {
+			p, err := strconv.ParseFloat(price(card), 64)
+			return err == nil && compare(p, v, op)
+		}), nil
+	}
+}
+
+func isTerm(op, value string) (node, error) {
+	if err := requireColon(op); err != nil {
+		return nil, err
+	}
+
+	var f func(card scryfall.Card) bool
+
+	switch strings.ToLower(value) {
+	case "foil":
+		f = deckbox.FinishFoil.AvailableFor
+	case "nonfoil":
+		f = deckbox.FinishNonfoil.AvailableFor
+	case "etched":
+		f = deckbox.FinishEtched.AvailableFor
+	case "glossy":
+		f = deckbox.FinishGlossy.AvailableFor
+	case "digital":
+		f = func(card scryfall.Card) bool { return card.Digital }
+	case "promo":
+		f = func(card scryfall.Card) bool { return card.Promo }
+	default:
+		return nil, fmt.Errorf("unknown value, expected one of: foil, nonfoil, etched, glossy, digital, promo")
+	}
+
+	return matchFunc(f), nil
+}
+
+func formatTerm(op, value string) (node, error) {
+	if err := requireColon(op); err != nil {
+		return nil, err
+	}
+
+	legality, ok := formatLegality(strings.ToLower(value))
+	if !ok {
+		return nil, fmt.Errorf("unknown format '%s'", value)
+	}
+
+	return matchFunc(func(card scryfall.Card) bool {
+		return legality(card) == "legal"
+	}), nil
+}
+
+func formatLegality(format string) (func(card scryfall.Card) string, bool) {
+	var f func(card scryfall.Card) string
+
+	switch format {
+	case "standard":
+		f = func(card scryfall.Card) string { return card.Legalities.Standard }
+	case "future":
+		f = func(card scryfall.Card) string { return card.Legalities.Future }
+	case "historic":
+		f = func(card scryfall.Card) string { return card.Legalities.Historic }
+	case "gladiator":
+		f = func(card scryfall.Card) string { return card.Legalities.Gladiator }
+	case "pioneer":
+		f = func(card scryfall.Card) string { return card.Legalities.Pioneer }
+	case "explorer":
+		f = func(card scryfall.Card) string { return card.Legalities.Explorer }
+	case "modern":
+		f = func(card scryfall.Card) string { return card.Legalities.Modern }
+	case "legacy":
+		f = func(card scryfall.Card) string { return card.Legalities.Legacy }
+	case "pauper":
+		f = func(card scryfall.Card) string { return card.Legalities.Pauper }
+	case "vintage":
+		f = func(card scryfall.Card) string { return card.Legalities.Vintage }
+	case "penny":
+		f = func(card scryfall.Card) string { return card.Legalities.Penny }
+	case "commander", "edh":
+		f = func(card scryfall.Card) string { return card.Legalities.Commander }
+	case "brawl":
+		f = func(card scryfall.Card) string { return card.Legalities.Brawl }
+	case "historicbrawl":
+		f = func(card scryfall.Card) string { return card.Legalities.Historicbrawl }
+	case "alchemy":
+		f = func(card scryfall.Card) string { return card.Legalities.Alchemy }
+	case "paupercommander":
+		f = func(card scryfall.Card) string { return card.Legalities.Paupercommander }
+	case "duel":
+		f = func(card scryfall.Card) string { return card.Legalities.Duel }
+	case "oldschool":
+		f = func(card scryfall.Card) string { return card.Legalities.Oldschool }
+	case "premodern":
+		f = func(card scryfall.Card) string { return card.Legalities.Premodern }
+	default:
+		return nil, false
+	}
+
+	return f, true
+}
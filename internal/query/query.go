@@ -0,0 +1,421 @@
+package query
+
+import (
+	"fmt"
+	"mtg-bulk-input/internal/data"
+	"mtg-bulk-input/internal/scryfall"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+/*
+Query is a parsed Scryfall style search, e.g. `t:creature (c:r OR c:g) mv<=2 -is:digital`.
+Terms next to each other must all match, OR matches either side and a leading '-' negates a term or group.
+Words without a keyword search the card name.
+*/
+type Query struct {
+	root node
+}
+
+/*
+Match reports whether the card matches the query
+*/
+func (q Query) Match(card scryfall.Card) bool {
+	return q.root.match(candidate{card: card, name: data.FoldName(card.Name), front: data.FoldName(frontFaceName(card))})
+}
+
+/*
+Search returns every card in the store matching the query, sorted by name and then newest first
+*/
+func Search(store data.Store, q Query) []scryfall.Card {
+	out := make([]scryfall.Card, 0)
+
+	// Names are folded when the index is built, so they're looked up rather than folded again for every card
+	for _, cards := range store.SetCards {
+		for _, card := range cards {
+			c := candidate{card: card, name: store.Index.Folded(card.Name), front: store.Index.Folded(frontFaceName(card))}
+
+			if q.root.match(c) {
+				out = append(out, card)
+			}
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+
+		if out[i].ReleasedAt != out[j].ReleasedAt {
+			return out[i].ReleasedAt > out[j].ReleasedAt
+		}
+
+		if out[i].Set != out[j].Set {
+			return out[i].Set < out[j].Set
+		}
+
+		return out[i].CollectorNumber < out[j].CollectorNumber
+	})
+
+	return out
+}
+
+/*
+LooksLikeQuery guesses whether text is meant as a query rather than a card name, used by Quick Search which accepts
+both. Card names can contain ':' (e.g. "Circle of Protection: Red") so callers should fall back to a name search if
+parsing fails.
+*/
+func LooksLikeQuery(text string) bool {
+	text = strings.TrimSpace(text)
+
+	return strings.ContainsAny(text, ":<>=") ||
+		strings.HasPrefix(text, "-") ||
+		strings.HasPrefix(text, "(") ||
+		strings.HasPrefix(text, "!")
+}
+
+/*
+candidate is a card being matched along with its folded name and front face name, folded once per card rather than
+by every name term
+*/
+type candidate struct {
+	card  scryfall.Card
+	name  string
+	front string
+}
+
+type node interface {
+	match(c candidate) bool
+}
+
+type andNode []node
+
+func (n andNode) match(c candidate) bool {
+	for _, child := range n {
+		if !child.match(c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type orNode []node
+
+func (n orNode) match(c candidate) bool {
+	for _, child := range n {
+		if child.match(c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type notNode struct {
+	node node
+}
+
+func (n notNode) match(c candidate) bool {
+	return !n.node.match(c)
+}
+
+type matchFunc func(card scryfall.Card) bool
+
+func (f matchFunc) match(c candidate) bool {
+	return f(c.card)
+}
+
+type tokenKind int
+
+const (
+	tokenTerm tokenKind = iota
+	tokenOpen
+	tokenClose
+	tokenOr
+	tokenNot
+)
+
+type token struct {
+	kind tokenKind
+
+	// For tokenTerm, the text with any quotes removed
+	text string
+
+	// Whether the term started with a quote, so `"t:goblin"` is a name and not a keyword
+	quoted bool
+}
+
+/*
+tokenize splits a query into terms, parentheses, OR and negation. Quoted text ("draw a card") can hold spaces.
+*/
+func tokenize(s string) ([]token, error) {
+	out := make([]token, 0)
+
+	runes := []rune(s)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			out = append(out, token{kind: tokenOpen})
+			i++
+			continue
+		case r == ')':
+			out = append(out, token{kind: tokenClose})
+			i++
+			continue
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]):
+			out = append(out, token{kind: tokenNot})
+			i++
+			continue
+		}
+
+		var sb strings.Builder
+
+		quoted := r == '"'
+		inQuote := false
+
+		for ; i < len(runes); i++ {
+			r = runes[i]
+
+			if r == '"' {
+				inQuote = !inQuote
+				continue
+			}
+
+			if !inQuote && (unicode.IsSpace(r) || r == '(' || r == ')') {
+				break
+			}
+
+			sb.WriteRune(r)
+		}
+
+		if inQuote {
+			return nil, fmt.Errorf("unclosed quote")
+		}
+
+		text := sb.String()
+
+		switch {
+		case !quoted && strings.EqualFold(text, "or"):
+			out = append(out, token{kind: tokenOr})
+		case !quoted && strings.EqualFold(text, "and"):
+			// Terms are ANDed anyway
+		default:
+			out = append(out, token{kind: tokenTerm, text: text, quoted: quoted})
+		}
+	}
+
+	return out, nil
+}
+
+/*
+Parse parses a query, returning an error describing the first problem found
+*/
+func Parse(s string) (Query, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return Query{}, err
+	}
+
+	if len(tokens) == 0 {
+		return Query{}, fmt.Errorf("empty query")
+	}
+
+	p := &parser{tokens: tokens}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return Query{}, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return Query{}, fmt.Errorf("unexpected ')'")
+	}
+
+	return Query{root: root}, nil
+}
+
+/*
+parser is a recursive descent parser over the tokens, OR binds looser than AND which binds looser than negation
+*/
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) parseOr() (node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	out := orNode{first}
+
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != tokenOr {
+			break
+		}
+
+		p.pos++
+
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, next)
+	}
+
+	if len(out) == 1 {
+		return first, nil
+	}
+
+	return out, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	out := andNode{}
+
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind == tokenOr || t.kind == tokenClose {
+			break
+		}
+
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, n)
+	}
+
+	switch len(out) {
+	case 0:
+		return nil, fmt.Errorf("expected a search term")
+	case 1:
+		return out[0], nil
+	default:
+		return out, nil
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t, _ := p.peek()
+	p.pos++
+
+	switch t.kind {
+	case tokenNot:
+		if _, ok := p.peek(); !ok {
+			return nil, fmt.Errorf("expected a search term after '-'")
+		}
+
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return notNode{node: n}, nil
+	case tokenOpen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if t, ok := p.peek(); !ok || t.kind != tokenClose {
+			return nil, fmt.Errorf("missing ')'")
+		}
+
+		p.pos++
+
+		return n, nil
+	case tokenTerm:
+		return parseTerm(t)
+	default:
+		return nil, fmt.Errorf("expected a search term")
+	}
+}
+
+var termRegex = regexp.MustCompile(`^([a-zA-Z]+)(:|!=|>=|<=|=|>|<)(.*)$`)
+
+/*
+parseTerm turns a single term, e.g. "t:goblin" or "usd<1", into a matcher
+*/
+func parseTerm(t token) (node, error) {
+	if strings.HasPrefix(t.text, "!") {
+		name := data.FoldName(strings.TrimPrefix(t.text, "!"))
+		if name == "" {
+			return nil, fmt.Errorf("expected a name after '!'")
+		}
+
+		return exactNameNode(name), nil
+	}
+
+	match := termRegex.FindStringSubmatch(t.text)
+	if t.quoted || match == nil {
+		return nameTerm(t.text)
+	}
+
+	key, op, value := strings.ToLower(match[1]), match[2], match[3]
+
+	if value == "" {
+		return nil, fmt.Errorf("'%s%s' needs a value", key, op)
+	}
+
+	newTerm, ok := keywords[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown keyword '%s'", key)
+	}
+
+	n, err := newTerm(op, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s%s%s: %w", key, op, value, err)
+	}
+
+	return n, nil
+}
+
+func nameTerm(text string) (node, error) {
+	folded := data.FoldName(text)
+	if folded == "" {
+		return nil, fmt.Errorf("'%s' is not a name", text)
+	}
+
+	return nameNode(folded), nil
+}
+
+// Matches folded names containing it
+type nameNode string
+
+func (n nameNode) match(c candidate) bool {
+	return strings.Contains(c.name, string(n))
+}
+
+// Matches the folded name, or front face name, exactly
+type exactNameNode string
+
+func (n exactNameNode) match(c candidate) bool {
+	return c.name == string(n) || c.front == string(n)
+}
+
+func frontFaceName(card scryfall.Card) string {
+	front, _, _ := strings.Cut(card.Name, " // ")
+	return front
+}
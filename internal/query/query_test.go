@@ -0,0 +1,80 @@
+package query
+
+import (
+	"mtg-bulk-input/internal/data"
+	"mtg-bulk-input/internal/scryfall"
+	"reflect"
+	"testing"
+)
+
+func testStore(cards ...scryfall.Card) data.Store {
+	store := data.Store{
+		SetCards: make(map[string]map[string]scryfall.Card),
+		Index:    data.NewCardIndex(),
+	}
+
+	for _, c := range cards {
+		if store.SetCards[c.Set] == nil {
+			store.SetCards[c.Set] = make(map[string]scryfall.Card)
+		}
+
+		store.SetCards[c.Set][c.CollectorNumber] = c
+		store.Index.Add(c)
+	}
+
+	store.Index.Sort()
+
+	return store
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, text := range []string{"-", "!", `!""`, "!'", "t:", "(t:goblin", "nope:x"} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", text)
+		}
+	}
+}
+
+func TestSearchNames(t *testing.T) {
+	store := testStore(
+		scryfall.Card{Name: "Lightning Bolt", Set: "m10", CollectorNumber: "146"},
+		scryfall.Card{Name: "Lim-Dûl's Vault", Set: "all", CollectorNumber: "48"},
+		scryfall.Card{Name: "Delver of Secrets // Insectile Aberration", Set: "isd", CollectorNumber: "51"},
+		scryfall.Card{Name: "Bolt Bend", Set: "war", CollectorNumber: "115"},
+	)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"bolt", []string{"Bolt Bend", "Lightning Bolt"}},
+		{"dul", []string{"Lim-Dûl's Vault"}},
+		{`!"lim-dul's vault"`, []string{"Lim-Dûl's Vault"}},
+		{`!"delver of secrets"`, []string{"Delver of Secrets // Insectile Aberration"}},
+		{`!bolt`, []string{}},
+		{`-bolt s:isd`, []string{"Delver of Secrets // Insectile Aberration"}},
+	}
+
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.query, err)
+			continue
+		}
+
+		got := make([]string, 0)
+		for _, card := range Search(store, q) {
+			got = append(got, card.Name)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+
+		for _, card := range Search(store, q) {
+			if !q.Match(card) {
+				t.Errorf("%q: Match disagrees with Search for %s", tt.query, card.Name)
+			}
+		}
+	}
+}
@@ -2,59 +2,305 @@ package scryfall
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
-	baseScryfallApiPath = "https://api.scryfall.com/"
-)
+	DefaultBaseUrl   = "https://api.scryfall.com/"
+	DefaultUserAgent = "deckbox-csv-generator/1.0"
+	DefaultTimeout   = time.Minute * 10
+
+	// Scryfall asks for no more than 10 requests per second
+	DefaultRequestsPerSecond = 10
+	DefaultMaxRetries        = 5
 
-var (
-	httpClient http.Client
+	initialBackoff = time.Millisecond * 500
+	maxBackoff     = time.Second * 30
+
+	// The longest Retry-After that is waited for, a request asked to wait any longer fails with ErrRetryAfterTooLong
+	MaxRetryAfter = time.Minute * 5
 )
 
-func init() {
-	httpClient = http.Client{}
+/*
+Client talks to the scryfall API, all requests go through the configured http.Client against the base URL
+*/
+type Client struct {
+	baseUrl    string
+	userAgent  string
+	timeout    time.Duration
+	httpClient *http.Client
+
+	limiter    *limiter
+	maxRetries int
+}
+
+type Option func(c *Client)
+
+/*
+WithBaseUrl points the client at a different API root (e.g. a mirror or a local fake server)
+*/
+func WithBaseUrl(baseUrl string) Option {
+	return func(c *Client) {
+		c.baseUrl = baseUrl
+	}
+}
+
+/*
+WithHttpClient replaces the underlying http.Client (e.g. to configure a proxy or transport)
+*/
+func WithHttpClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+/*
+WithTimeout overrides the timeout of the underlying http.Client, a provided http.Client is copied rather than modified
+*/
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+/*
+WithRateLimit sets the maximum number of requests sent per second, 0 disables the limit
+*/
+func WithRateLimit(perSecond float64) Option {
+	return func(c *Client) {
+		c.limiter = newLimiter(perSecond)
+	}
+}
+
+/*
+WithMaxRetries sets how many times a request is retried after a 429, 5xx or transport failure
+*/
+func WithMaxRetries(maxRetries int) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+	}
+}
+
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		baseUrl:   DefaultBaseUrl,
+		userAgent: DefaultUserAgent,
+		httpClient: &http.Client{
+			Timeout: DefaultTimeout,
+		},
+		limiter:    newLimiter(DefaultRequestsPerSecond),
+		maxRetries: DefaultMaxRetries,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.timeout > 0 {
+		httpClient := *c.httpClient
+		httpClient.Timeout = c.timeout
+		c.httpClient = &httpClient
+	}
+
+	return c
+}
+
+var ErrRangeIgnored = errors.New("server did not honour the requested byte range")
+
+var ErrRetryAfterTooLong = errors.New("server asked to retry later than MaxRetryAfter")
+
+/*
+StatusError is returned for a response with an unexpected status code that didn't come with a scryfall Error
+*/
+type StatusError struct {
+	StatusCode int
+	err        error
+}
+
+func (e *StatusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.err
+}
+
+/*
+Retryable reports whether a request that failed with err may succeed if it is sent again. Transport failures,
+ErrRangeIgnored and the statuses do retries (see isRetryableStatus) are retryable, any other status (e.g. a 404)
+and ErrRetryAfterTooLong are not.
+*/
+func Retryable(err error) bool {
+	if errors.Is(err, ErrRangeIgnored) {
+		return true
+	}
+
+	if errors.Is(err, ErrRetryAfterTooLong) {
+		return false
+	}
+
+	var scryfallErr Error
+	if errors.As(err, &scryfallErr) {
+		return isRetryableStatus(scryfallErr.Status)
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return isRetryableStatus(statusErr.StatusCode)
+	}
+
+	return true
 }
 
 func handleRespStatus(resp *http.Response) error {
 	dec := json.NewDecoder(resp.Body)
 
-	if resp.StatusCode > 400 {
+	if resp.StatusCode >= 400 {
 		var returnedError Error
 
 		err := dec.Decode(&returnedError)
 		if err != nil {
-			return fmt.Errorf("non 200 status code (%d) with unparseable error body: %w", resp.StatusCode, err)
+			return &StatusError{
+				StatusCode: resp.StatusCode,
+				err:        fmt.Errorf("non 200 status code (%d) with unparseable error body: %w", resp.StatusCode, err),
+			}
+		}
+
+		if returnedError.Status == 0 {
+			returnedError.Status = resp.StatusCode
 		}
 
 		return returnedError
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("non 200, non error status code (%d)", resp.StatusCode)
+		return &StatusError{
+			StatusCode: resp.StatusCode,
+			err:        fmt.Errorf("non 200, non error status code (%d)", resp.StatusCode),
+		}
 	}
 
 	return nil
 }
 
-func getJson(path string, out any) error {
-	url, err := url.JoinPath(baseScryfallApiPath, path)
+func (c *Client) newRequest(url string, accept string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct request: %w", err)
+	}
+
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	req.Header.Set("Accept", accept)
+
+	return req, nil
+}
+
+/*
+do sends the request through the rate limiter, retrying with exponential backoff on
+transport errors, 429 and 5xx responses. A Retry-After header takes precedence over the backoff for that
+one retry without changing the backoff of later retries. If it asks for longer than MaxRetryAfter the request
+fails with ErrRetryAfterTooLong rather than waiting.
+The returned response has not had its status checked beyond the retryable codes.
+*/
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	backoff := initialBackoff
+
+	for attempt := 0; ; attempt++ {
+		c.limiter.wait()
+
+		wait := backoff
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			if attempt >= c.maxRetries {
+				return nil, fmt.Errorf("failed to send request after %d attempts: %w", attempt+1, err)
+			}
+		} else if !isRetryableStatus(resp.StatusCode) || attempt >= c.maxRetries {
+			return resp, nil
+		} else {
+			// Drain so the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				if retryAfter > MaxRetryAfter {
+					return nil, fmt.Errorf("%w: status %d with Retry-After %s", ErrRetryAfterTooLong, resp.StatusCode, retryAfter)
+				}
+
+				wait = retryAfter
+			}
+		}
+
+		time.Sleep(wait)
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests ||
+		code == http.StatusInternalServerError ||
+		code == http.StatusBadGateway ||
+		code == http.StatusServiceUnavailable ||
+		code == http.StatusGatewayTimeout
+}
+
+/*
+parseRetryAfter handles both forms of the Retry-After header: delay-seconds and an HTTP date
+*/
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return 0, false
+}
+
+func (c *Client) getJson(path string, out any) error {
+	url, err := url.JoinPath(c.baseUrl, path)
 	if err != nil {
 		return fmt.Errorf("failed to combine path: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := c.newRequest(url, "application/json")
 	if err != nil {
-		return fmt.Errorf("failed to construct request: %w", err)
+		return err
 	}
 
-	resp, err := httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
 
@@ -72,10 +318,10 @@ func getJson(path string, out any) error {
 	return nil
 }
 
-func GetBulkDataMeta() (BulkDataList, error) {
+func (c *Client) GetBulkDataMeta() (BulkDataList, error) {
 	var out BulkDataList
 
-	err := getJson("/bulk-data", &out)
+	err := c.getJson("/bulk-data", &out)
 	if err != nil {
 		return BulkDataList{}, fmt.Errorf("failed to get from bulk-data endpoint: %w", err)
 	}
@@ -83,26 +329,53 @@ func GetBulkDataMeta() (BulkDataList, error) {
 	return out, nil
 }
 
-func DownloadBulkFile(url string, w io.Writer) error {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+/*
+DownloadBulkFile copies the file at url into w. When offset is greater than zero only the bytes from
+offset onwards are requested, if the server will not serve that range ErrRangeIgnored is returned and
+nothing is written, so the caller should start again from zero.
+*/
+func (c *Client) DownloadBulkFile(url string, w io.Writer, offset int64) error {
+	req, err := c.newRequest(url, "*/*")
 	if err != nil {
-		return fmt.Errorf("failed to construct request: %w", err)
+		return err
+	}
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
 	}
 
-	resp, err := httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
 
-	if err = handleRespStatus(resp); err != nil {
+	if offset > 0 {
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+			if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+				return ErrRangeIgnored
+			}
+		case http.StatusOK, http.StatusRequestedRangeNotSatisfiable:
+			return ErrRangeIgnored
+		default:
+			if err = handleRespStatus(resp); err != nil {
+				return err
+			}
+		}
+	} else if err = handleRespStatus(resp); err != nil {
 		return err
 	}
 
+	// Let a progress reporter know the size if it wasn't already known
+	if pw, ok := w.(*ProgressWriter); ok && resp.ContentLength > 0 {
+		pw.SetTotal(offset + resp.ContentLength)
+	}
+
 	_, err = io.Copy(w, resp.Body)
 	if err != nil {
 		return fmt.Errorf("failed while downloading request body: %w", err)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
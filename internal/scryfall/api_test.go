@@ -0,0 +1,88 @@
+package scryfall
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDoRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		wantErr    error
+		minWait    time.Duration
+	}{
+		{"honoured", "1", nil, time.Second},
+		{"too long", fmt.Sprint(int(MaxRetryAfter/time.Second) + 1), ErrRetryAfterTooLong, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+
+				if requests == 1 {
+					w.Header().Set("Retry-After", tt.retryAfter)
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			c := NewClient(WithBaseUrl(server.URL), WithRateLimit(0))
+
+			req, err := c.newRequest(server.URL, "application/json")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+
+			resp, err := c.do(req)
+			if resp != nil {
+				resp.Body.Close()
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if waited := time.Since(start); waited < tt.minWait {
+				t.Errorf("retried after %s, want at least %s", waited, tt.minWait)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("connection reset"), true},
+		{ErrRangeIgnored, true},
+		{fmt.Errorf("wrapped: %w", ErrRetryAfterTooLong), false},
+		{Error{Status: http.StatusNotFound}, false},
+		{Error{Status: http.StatusTooManyRequests}, true},
+		{Error{Status: http.StatusServiceUnavailable}, true},
+		{&StatusError{StatusCode: http.StatusForbidden, err: errors.New("forbidden")}, false},
+		{&StatusError{StatusCode: http.StatusBadGateway, err: errors.New("bad gateway")}, true},
+
+		// Not retried by do either
+		{&StatusError{StatusCode: http.StatusNotImplemented, err: errors.New("not implemented")}, false},
+	}
+
+	for _, tt := range tests {
+		if got := Retryable(tt.err); got != tt.want {
+			t.Errorf("Retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
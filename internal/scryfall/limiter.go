@@ -0,0 +1,49 @@
+package scryfall
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+limiter spaces out requests so that no more than a fixed number are sent per second.
+Scryfall asks for 50-100ms between requests, so this is deliberately simple rather than a token bucket.
+*/
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newLimiter(perSecond float64) *limiter {
+	var interval time.Duration
+	if perSecond > 0 {
+		interval = time.Duration(float64(time.Second) / perSecond)
+	}
+
+	return &limiter{
+		interval: interval,
+	}
+}
+
+/*
+wait blocks until the caller is allowed to send its next request
+*/
+func (l *limiter) wait() {
+	if l.interval == 0 {
+		return
+	}
+
+	l.mu.Lock()
+
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+
+	l.mu.Unlock()
+
+	time.Sleep(time.Until(slot))
+}
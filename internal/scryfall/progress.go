@@ -0,0 +1,119 @@
+package scryfall
+
+import (
+	"io"
+	"time"
+)
+
+// How often a ProgressWriter reports while data is flowing
+const progressInterval = time.Millisecond * 200
+
+/*
+Progress is a snapshot of a running download
+*/
+type Progress struct {
+	// Bytes written so far, including any bytes from a resumed download
+	Done int64
+
+	// Total size in bytes, 0 if unknown
+	Total int64
+
+	// Bytes per second since the ProgressWriter was created
+	Rate float64
+
+	// Estimated time remaining, 0 if unknown
+	ETA time.Duration
+
+	// Set on the final report
+	Finished bool
+}
+
+type ProgressFunc func(p Progress)
+
+/*
+ProgressWriter wraps an io.Writer and periodically reports how much has been written through it
+*/
+type ProgressWriter struct {
+	w  io.Writer
+	fn ProgressFunc
+
+	start      time.Time
+	lastReport time.Time
+
+	// Bytes that were already present when the writer was created (e.g. a resumed download)
+	initial int64
+
+	done  int64
+	total int64
+}
+
+/*
+NewProgressWriter reports progress of writes to w through fn. initial is the number of bytes already
+downloaded and total the expected final size, which can be 0 if it's not yet known.
+*/
+func NewProgressWriter(w io.Writer, initial, total int64, fn ProgressFunc) *ProgressWriter {
+	now := time.Now()
+
+	return &ProgressWriter{
+		w:       w,
+		fn:      fn,
+		start:   now,
+		initial: initial,
+		done:    initial,
+		total:   total,
+	}
+}
+
+/*
+SetTotal sets the expected size if it wasn't known when the writer was created,
+DownloadBulkFile calls this with the response Content-Length
+*/
+func (pw *ProgressWriter) SetTotal(total int64) {
+	if pw.total <= 0 {
+		pw.total = total
+	}
+}
+
+func (pw *ProgressWriter) Write(b []byte) (int, error) {
+	n, err := pw.w.Write(b)
+	pw.done += int64(n)
+
+	if time.Since(pw.lastReport) >= progressInterval {
+		pw.report(false)
+	}
+
+	return n, err
+}
+
+/*
+Finish sends a final report, it should be called once the download has completed
+*/
+func (pw *ProgressWriter) Finish() {
+	pw.report(true)
+}
+
+func (pw *ProgressWriter) report(finished bool) {
+	now := time.Now()
+	pw.lastReport = now
+
+	if pw.fn == nil {
+		return
+	}
+
+	p := Progress{
+		Done:     pw.done,
+		Total:    pw.total,
+		Finished: finished,
+	}
+
+	elapsed := now.Sub(pw.start).Seconds()
+	if elapsed > 0 {
+		p.Rate = float64(pw.done-pw.initial) / elapsed
+	}
+
+	if p.Rate > 0 && p.Total > p.Done {
+		p.ETA = time.Duration(float64(p.Total-p.Done) / p.Rate * float64(time.Second))
+	}
+
+	pw.fn(p)
+}
@@ -146,12 +146,12 @@ type Card struct {
 	EdhrecRank      int      `json:"edhrec_rank"`
 	PennyRank       int      `json:"penny_rank"`
 	Prices          struct {
-		Usd       string      `json:"usd"`
-		UsdFoil   string      `json:"usd_foil"`
-		UsdEtched interface{} `json:"usd_etched"`
-		Eur       string      `json:"eur"`
-		EurFoil   string      `json:"eur_foil"`
-		Tix       string      `json:"tix"`
+		Usd       string `json:"usd"`
+		UsdFoil   string `json:"usd_foil"`
+		UsdEtched string `json:"usd_etched"`
+		Eur       string `json:"eur"`
+		EurFoil   string `json:"eur_foil"`
+		Tix       string `json:"tix"`
 	} `json:"prices"`
 	RelatedUris struct {
 		Gatherer                  string `json:"gatherer"`
@@ -159,4 +159,34 @@ type Card struct {
 		TcgplayerInfiniteDecks    string `json:"tcgplayer_infinite_decks"`
 		Edhrec                    string `json:"edhrec"`
 	} `json:"related_uris"`
+	CardFaces []CardFace `json:"card_faces"`
+}
+
+/*
+Rulings
+*/
+type Ruling struct {
+	Object      string `json:"object"`
+	OracleId    string `json:"oracle_id"`
+	Source      string `json:"source"`
+	PublishedAt string `json:"published_at"`
+	Comment     string `json:"comment"`
+}
+
+/*
+CardFace is one face of a multi-faced card (transform, modal_dfc, split, flip, adventure etc.)
+*/
+type CardFace struct {
+	Object         string   `json:"object"`
+	Name           string   `json:"name"`
+	ManaCost       string   `json:"mana_cost"`
+	TypeLine       string   `json:"type_line"`
+	OracleText     string   `json:"oracle_text"`
+	Colors         []string `json:"colors"`
+	Power          string   `json:"power"`
+	Toughness      string   `json:"toughness"`
+	Loyalty        string   `json:"loyalty"`
+	FlavorText     string   `json:"flavor_text"`
+	Artist         string   `json:"artist"`
+	IllustrationId string   `json:"illustration_id"`
 }
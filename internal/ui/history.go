@@ -0,0 +1,242 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mtg-bulk-input/internal/deckbox"
+	"os"
+	"strings"
+)
+
+// How many operations are kept to be undone
+const historyLimit = 200
+
+var errHistoryMismatch = errors.New("the history doesn't match the selected cards")
+
+type operationKind string
+
+const (
+	opAdd       operationKind = "add"
+	opIncrement operationKind = "increment"
+	opDecrement operationKind = "decrement"
+	opDelete    operationKind = "delete"
+	opEdit      operationKind = "edit"
+	opImport    operationKind = "import"
+)
+
+/*
+rowChange is a change to one row of the selected cards. Before is nil when the row is inserted at Index and After
+is nil when it is removed.
+*/
+type rowChange struct {
+	Index  int
+	Before *deckbox.SelectedCard `json:",omitempty"`
+	After  *deckbox.SelectedCard `json:",omitempty"`
+}
+
+/*
+apply makes the change to cards, which may be modified. The row is checked against Before first so a history that
+no longer matches (e.g. the session file was edited by hand) isn't applied to the wrong rows.
+*/
+func (c rowChange) apply(cards []deckbox.SelectedCard) ([]deckbox.SelectedCard, error) {
+	if c.Before == nil {
+		if c.After == nil || c.Index < 0 || c.Index > len(cards) {
+			return cards, errHistoryMismatch
+		}
+
+		cards = append(cards, deckbox.SelectedCard{})
+		copy(cards[c.Index+1:], cards[c.Index:])
+		cards[c.Index] = *c.After
+
+		return cards, nil
+	}
+
+	if c.Index < 0 || c.Index >= len(cards) || cards[c.Index] != *c.Before {
+		return cards, errHistoryMismatch
+	}
+
+	if c.After == nil {
+		return append(cards[:c.Index], cards[c.Index+1:]...), nil
+	}
+
+	cards[c.Index] = *c.After
+
+	return cards, nil
+}
+
+func (c rowChange) inverse() rowChange {
+	return rowChange{
+		Index:  c.Index,
+		Before: c.After,
+		After:  c.Before,
+	}
+}
+
+/*
+operation is one thing the user did to the selected cards, which can change several rows (e.g. an import)
+*/
+type operation struct {
+	Kind    operationKind
+	Changes []rowChange
+}
+
+/*
+apply returns a copy of cards with the operation's changes made, cards is left as is
+*/
+func (op operation) apply(cards []deckbox.SelectedCard) ([]deckbox.SelectedCard, error) {
+	out := make([]deckbox.SelectedCard, len(cards), len(cards)+len(op.Changes))
+	copy(out, cards)
+
+	var err error
+
+	for _, c := range op.Changes {
+		out, err = c.apply(out)
+		if err != nil {
+			return cards, err
+		}
+	}
+
+	return out, nil
+}
+
+/*
+inverse is the operation that undoes op
+*/
+func (op operation) inverse() operation {
+	out := operation{
+		Kind:    op.Kind,
+		Changes: make([]rowChange, 0, len(op.Changes)),
+	}
+
+	for i := len(op.Changes) - 1; i >= 0; i-- {
+		out.Changes = append(out.Changes, op.Changes[i].inverse())
+	}
+
+	return out
+}
+
+/*
+history holds the operations that can be undone and redone, most recent last.
+It's saved next to the session file so it survives restarts.
+*/
+type history struct {
+	Undo []operation
+	Redo []operation
+}
+
+func historyPath(sessionPath string) string {
+	return strings.TrimSuffix(sessionPath, ".json") + ".history.json"
+}
+
+/*
+loadHistory reads the history saved next to the session file. The history is only there to undo changes, so if it
+can't be read the session is opened without one rather than not at all.
+*/
+func loadHistory(sessionPath string) history {
+	var out history
+
+	p := historyPath(sessionPath)
+
+	b, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return out
+	} else if err != nil {
+		log.Printf("WARNING: could not open history file '%s', starting a new history: %v", p, err)
+		return history{}
+	}
+
+	err = json.Unmarshal(b, &out)
+	if err != nil {
+		log.Printf("WARNING: failed to parse history file '%s' as json, starting a new history: %v", p, err)
+		return history{}
+	}
+
+	return out
+}
+
+/*
+mergeChange is the change that adds sCard to cards, combining it with an existing row for the same card if there is one
+*/
+func mergeChange(cards []deckbox.SelectedCard, sCard deckbox.SelectedCard) rowChange {
+	for i, c := range cards {
+		if c.SameCard(sCard) {
+			before := c
+
+			c.Quantity += sCard.Quantity
+			c.TradelistCount += sCard.TradelistCount
+
+			return rowChange{Index: i, Before: &before, After: &c}
+		}
+	}
+
+	return rowChange{Index: len(cards), After: &sCard}
+}
+
+/*
+do makes an operation to the selected cards and records it so it can be undone
+*/
+func (a *app) do(op operation) error {
+	cards, err := op.apply(a.selectedCards)
+	if err != nil {
+		return err
+	}
+
+	a.selectedCards = cards
+
+	a.history.Undo = append(a.history.Undo, op)
+	if len(a.history.Undo) > historyLimit {
+		a.history.Undo = a.history.Undo[len(a.history.Undo)-historyLimit:]
+	}
+
+	a.history.Redo = nil
+
+	return nil
+}
+
+/*
+Undo reverts the most recent operation, returning it. If the history doesn't match the selected cards it is cleared.
+*/
+func (a *app) Undo() (operation, error) {
+	if len(a.history.Undo) == 0 {
+		return operation{}, fmt.Errorf("nothing to undo")
+	}
+
+	op := a.history.Undo[len(a.history.Undo)-1]
+
+	cards, err := op.inverse().apply(a.selectedCards)
+	if err != nil {
+		a.history = history{}
+		return op, err
+	}
+
+	a.selectedCards = cards
+	a.history.Undo = a.history.Undo[:len(a.history.Undo)-1]
+	a.history.Redo = append(a.history.Redo, op)
+
+	return op, nil
+}
+
+/*
+Redo makes the most recently undone operation again, returning it
+*/
+func (a *app) Redo() (operation, error) {
+	if len(a.history.Redo) == 0 {
+		return operation{}, fmt.Errorf("nothing to redo")
+	}
+
+	op := a.history.Redo[len(a.history.Redo)-1]
+
+	cards, err := op.apply(a.selectedCards)
+	if err != nil {
+		a.history = history{}
+		return op, err
+	}
+
+	a.selectedCards = cards
+	a.history.Redo = a.history.Redo[:len(a.history.Redo)-1]
+	a.history.Undo = append(a.history.Undo, op)
+
+	return op, nil
+}
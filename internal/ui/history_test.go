@@ -0,0 +1,222 @@
+package ui
+
+import (
+	"errors"
+	"mtg-bulk-input/internal/deckbox"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var (
+	bolt    = deckbox.SelectedCard{Set: "m10", Number: "146", Quantity: 1, Finish: deckbox.FinishNonfoil}
+	counter = deckbox.SelectedCard{Set: "ice", Number: "64", Quantity: 2, Finish: deckbox.FinishNonfoil}
+	elves   = deckbox.SelectedCard{Set: "m19", Number: "314", Quantity: 4, Finish: deckbox.FinishFoil}
+)
+
+func withQuantity(sCard deckbox.SelectedCard, quantity int) *deckbox.SelectedCard {
+	sCard.Quantity = quantity
+	return &sCard
+}
+
+/*
+TestOperationRoundTrip makes each operation, undoes it and redoes it, checking the cards after each step
+*/
+func TestOperationRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		before []deckbox.SelectedCard
+		op     func(cards []deckbox.SelectedCard) operation
+		after  []deckbox.SelectedCard
+	}{
+		{
+			name:   "insert",
+			before: []deckbox.SelectedCard{bolt},
+			op: func(cards []deckbox.SelectedCard) operation {
+				return operation{Kind: opAdd, Changes: []rowChange{mergeChange(cards, counter)}}
+			},
+			after: []deckbox.SelectedCard{bolt, counter},
+		},
+		{
+			name:   "insert in the middle",
+			before: []deckbox.SelectedCard{bolt, elves},
+			op: func(cards []deckbox.SelectedCard) operation {
+				return operation{Kind: opAdd, Changes: []rowChange{{Index: 1, After: &counter}}}
+			},
+			after: []deckbox.SelectedCard{bolt, counter, elves},
+		},
+		{
+			name:   "delete",
+			before: []deckbox.SelectedCard{bolt, counter, elves},
+			op: func(cards []deckbox.SelectedCard) operation {
+				return operation{Kind: opDelete, Changes: []rowChange{{Index: 1, Before: &counter}}}
+			},
+			after: []deckbox.SelectedCard{bolt, elves},
+		},
+		{
+			name:   "merge",
+			before: []deckbox.SelectedCard{bolt, counter},
+			op: func(cards []deckbox.SelectedCard) operation {
+				return operation{Kind: opAdd, Changes: []rowChange{mergeChange(cards, *withQuantity(counter, 3))}}
+			},
+			after: []deckbox.SelectedCard{bolt, *withQuantity(counter, 5)},
+		},
+		{
+			name:   "edit",
+			before: []deckbox.SelectedCard{bolt},
+			op: func(cards []deckbox.SelectedCard) operation {
+				return operation{Kind: opIncrement, Changes: []rowChange{{Index: 0, Before: &bolt, After: withQuantity(bolt, 2)}}}
+			},
+			after: []deckbox.SelectedCard{*withQuantity(bolt, 2)},
+		},
+		{
+			name:   "import of several rows",
+			before: []deckbox.SelectedCard{bolt},
+			op: func(cards []deckbox.SelectedCard) operation {
+				op := operation{Kind: opImport}
+
+				for _, sCard := range []deckbox.SelectedCard{counter, bolt, elves} {
+					change := mergeChange(cards, sCard)
+					op.Changes = append(op.Changes, change)
+
+					cards, _ = change.apply(append([]deckbox.SelectedCard(nil), cards...))
+				}
+
+				return op
+			},
+			after: []deckbox.SelectedCard{*withQuantity(bolt, 2), counter, elves},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &app{selectedCards: append([]deckbox.SelectedCard(nil), tt.before...)}
+
+			err := a.do(tt.op(a.selectedCards))
+			if err != nil {
+				t.Fatalf("do failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(a.selectedCards, tt.after) {
+				t.Fatalf("after do got %+v, want %+v", a.selectedCards, tt.after)
+			}
+
+			_, err = a.Undo()
+			if err != nil {
+				t.Fatalf("undo failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(a.selectedCards, tt.before) {
+				t.Fatalf("after undo got %+v, want %+v", a.selectedCards, tt.before)
+			}
+
+			_, err = a.Redo()
+			if err != nil {
+				t.Fatalf("redo failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(a.selectedCards, tt.after) {
+				t.Fatalf("after redo got %+v, want %+v", a.selectedCards, tt.after)
+			}
+
+			if len(a.history.Undo) != 1 || len(a.history.Redo) != 0 {
+				t.Errorf("got %d undo and %d redo operations, want 1 and 0", len(a.history.Undo), len(a.history.Redo))
+			}
+		})
+	}
+}
+
+func TestUndoMismatchClearsHistory(t *testing.T) {
+	a := &app{selectedCards: []deckbox.SelectedCard{bolt}}
+
+	err := a.do(operation{Kind: opAdd, Changes: []rowChange{mergeChange(a.selectedCards, counter)}})
+	if err != nil {
+		t.Fatalf("do failed: %v", err)
+	}
+
+	// As if the session file was edited by hand
+	a.selectedCards = []deckbox.SelectedCard{bolt, elves}
+
+	_, err = a.Undo()
+	if !errors.Is(err, errHistoryMismatch) {
+		t.Fatalf("got error %v, want errHistoryMismatch", err)
+	}
+
+	if !reflect.DeepEqual(a.selectedCards, []deckbox.SelectedCard{bolt, elves}) {
+		t.Errorf("cards were changed by a failed undo: %+v", a.selectedCards)
+	}
+
+	if len(a.history.Undo) != 0 || len(a.history.Redo) != 0 {
+		t.Errorf("history was not cleared: %+v", a.history)
+	}
+}
+
+func TestDoTrimsHistoryAndClearsRedo(t *testing.T) {
+	a := &app{selectedCards: []deckbox.SelectedCard{}}
+
+	for i := 0; i < historyLimit+10; i++ {
+		err := a.do(operation{Kind: opAdd, Changes: []rowChange{mergeChange(a.selectedCards, bolt)}})
+		if err != nil {
+			t.Fatalf("do %d failed: %v", i, err)
+		}
+	}
+
+	if len(a.history.Undo) != historyLimit {
+		t.Errorf("got %d undo operations, want %d", len(a.history.Undo), historyLimit)
+	}
+
+	_, err := a.Undo()
+	if err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+
+	err = a.do(operation{Kind: opAdd, Changes: []rowChange{mergeChange(a.selectedCards, counter)}})
+	if err != nil {
+		t.Fatalf("do failed: %v", err)
+	}
+
+	if len(a.history.Redo) != 0 {
+		t.Errorf("redo wasn't cleared by a new operation")
+	}
+}
+
+func TestLoadHistory(t *testing.T) {
+	dir := t.TempDir()
+	session := filepath.Join(dir, "cards.json")
+
+	if h := loadHistory(session); len(h.Undo) != 0 || len(h.Redo) != 0 {
+		t.Errorf("got %+v without a history file, want an empty history", h)
+	}
+
+	a := &app{filepath: session, selectedCards: []deckbox.SelectedCard{}}
+
+	err := a.do(operation{Kind: opAdd, Changes: []rowChange{mergeChange(a.selectedCards, bolt)}})
+	if err != nil {
+		t.Fatalf("do failed: %v", err)
+	}
+
+	cards, hist, err := a.encodeSession()
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	err = a.writeSession(cards, hist)
+	if err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	if h := loadHistory(session); !reflect.DeepEqual(h, a.history) {
+		t.Errorf("got %+v after saving, want %+v", h, a.history)
+	}
+
+	// Half written
+	err = os.WriteFile(historyPath(session), hist[:len(hist)/2], 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h := loadHistory(session); len(h.Undo) != 0 || len(h.Redo) != 0 {
+		t.Errorf("got %+v from a corrupt history file, want an empty history", h)
+	}
+}
@@ -6,8 +6,12 @@ import (
 	"fmt"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+	"io"
 	"mtg-bulk-input/internal/data"
 	"mtg-bulk-input/internal/deckbox"
+	"mtg-bulk-input/internal/export"
+	"mtg-bulk-input/internal/importer"
+	"mtg-bulk-input/internal/query"
 	"mtg-bulk-input/internal/scryfall"
 	"os"
 	"regexp"
@@ -20,20 +24,28 @@ import (
 )
 
 const (
-	cardInputRegex      = `^(?:([0-9a-z]{3})\.)?(\d+)(f?)$`
-	moxfieldImportRegex = `^(\d+)\s+(.+)\s+\(([0-9A-Z]{3})\)\s(\d+)(\s\*F\*)?$`
-
-	mainPageName        = "main"
-	importModalPageName = "importModal"
-	quickSearchPageName = "quickSearch"
+	cardInputRegex = `^(?:([0-9a-z]{3})\.)?(\d+)([feg]?)$`
+
+	mainPageName          = "main"
+	importModalPageName   = "importModal"
+	importResultsPageName = "importResults"
+	importPickerPageName  = "importPicker"
+	importFixPageName     = "importFix"
+	quickSearchPageName   = "quickSearch"
+	unmappedModalPageName = "unmappedModal"
+	editCardPageName      = "editCard"
+	messageModalPageName  = "messageModal"
+	exportFormatPageName  = "exportFormat"
 )
 
 var (
-	cardInputRegexEval      = regexp.MustCompile(cardInputRegex)
-	moxfieldImportRegexEval = regexp.MustCompile(moxfieldImportRegex)
+	cardInputRegexEval = regexp.MustCompile(cardInputRegex)
+
+	errNoPrinting        = errors.New("no such printing")
+	errFinishUnavailable = errors.New("finish not available")
 )
 
-func Start(filepath string, store data.Store) error {
+func Start(filepath string, store data.Store, editions deckbox.EditionMapper, exporter export.Exporter, names importer.NameResolution) error {
 	var selCards []deckbox.SelectedCard
 
 	b, err := os.ReadFile(filepath)
@@ -53,6 +65,12 @@ func Start(filepath string, store data.Store) error {
 
 		store: store,
 
+		editions: editions,
+
+		exporter: exporter,
+
+		names: names,
+
 		autosaveTimer: time.NewTicker(time.Second * 10),
 
 		selectedCards: selCards,
@@ -60,35 +78,72 @@ func Start(filepath string, store data.Store) error {
 		quickSearchCardsList: make([]scryfall.Card, 0),
 	}
 
-	go func() {
-		for {
-			_ = <-a.autosaveTimer.C
+	a.history = loadHistory(filepath)
 
-			b, err := json.Marshal(a.selectedCards)
-			if err != nil {
-				panic(err) // TODO: Do I need to handle this?
-			}
+	return a.start()
+}
 
-			err = os.WriteFile(filepath, b, 0755)
-			if err != nil {
-				panic(err)
-			}
-		}
-	}()
+/*
+encodeSession encodes the selected cards and their history for saving. Like everything else that reads them it must
+run on the UI goroutine, see tview.Application.QueueUpdate.
+*/
+func (a *app) encodeSession() ([]byte, []byte, error) {
+	cards, err := json.Marshal(a.selectedCards)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode selected cards: %w", err)
+	}
 
-	return a.start()
+	hist, err := json.Marshal(a.history)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode history: %w", err)
+	}
+
+	return cards, hist, nil
+}
+
+/*
+writeSession saves the output of encodeSession, each file is written to a temporary file and renamed into place
+*/
+func (a *app) writeSession(cards []byte, hist []byte) error {
+	err := data.WriteFileAtomic(a.filepath, 0644, func(w io.Writer) error {
+		_, err := w.Write(cards)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save selected cards to '%s': %w", a.filepath, err)
+	}
+
+	p := historyPath(a.filepath)
+
+	err = data.WriteFileAtomic(p, 0644, func(w io.Writer) error {
+		_, err := w.Write(hist)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save history to '%s': %w", p, err)
+	}
+
+	return nil
 }
 
 type cardMatch struct {
 	count   int
 	cardSet string
 	cardNum string
-	foil    bool
+	finish  deckbox.Finish
 }
 
 type app struct {
 	store data.Store
 
+	editions deckbox.EditionMapper
+
+	// The format chosen most recently, or from the command line
+	exporter export.Exporter
+
+	// How imported cards without a collector number are matched to a printing, changed from the import results
+	names importer.NameResolution
+
 	selectedSet string
 
 	selectedCards []deckbox.SelectedCard
@@ -98,6 +153,12 @@ type app struct {
 	filepath string
 
 	quickSearchCardsList []scryfall.Card
+
+	// Cards usually left out of Quick Search that have been asked for
+	searchInclude data.Exclusions
+
+	// Changes to selectedCards, which can be undone
+	history history
 }
 
 func (a *app) start() error {
@@ -109,6 +170,27 @@ func (a *app) start() error {
 	tviewApp := tview.NewApplication()
 	pages := tview.NewPages()
 
+	/*
+		Message Modal
+	*/
+
+	// Focus goes back to whatever had it before the message was shown
+	var messageReturnFocus tview.Primitive
+
+	messageModal := tview.NewModal().
+		AddButtons([]string{"OK"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			pages.HidePage(messageModalPageName)
+			tviewApp.SetFocus(messageReturnFocus)
+		})
+
+	showMessage := func(text string) {
+		messageReturnFocus = tviewApp.GetFocus()
+		messageModal.SetText(text)
+		pages.ShowPage(messageModalPageName)
+		tviewApp.SetFocus(messageModal)
+	}
+
 	/*
 		Card Table
 	*/
@@ -119,26 +201,67 @@ func (a *app) start() error {
 
 		switch event.Rune() {
 		case '+':
-			// Increment Quantity
-			sCard := a.selectedCards[row-1]
-			sCard.Quantity += 1
-			a.selectedCards[row-1] = sCard
+			if row < 1 || row > len(a.selectedCards) {
+				return nil
+			}
+
+			a.ChangeQuantity(row-1, 1)
 			return nil
 		case '-':
-			// Decrement Quantity
+			if row < 1 || row > len(a.selectedCards) {
+				return nil
+			}
 
-			sCard := a.selectedCards[row-1]
-			sCard.Quantity -= 1
+			a.ChangeQuantity(row-1, -1)
+			return nil
+		case 'D':
+			cardsTable.RemoveRow(row)
+			return nil
+		case 'u':
+			op, err := a.Undo()
+			if err != nil {
+				a.beep(1)
 
-			if sCard.Quantity == 0 {
-				cardsTable.RemoveRow(row)
-			} else {
-				a.selectedCards[row-1] = sCard
+				if errors.Is(err, errHistoryMismatch) {
+					showMessage(fmt.Sprintf("Could not undo %s, %v. The history has been cleared.", op.Kind, err))
+				}
+
+				return nil
+			}
+
+			if len(op.Changes) > 0 {
+				cardsTable.Select(op.Changes[0].Index+1, 0)
 			}
 			return nil
+		case 'r':
+			op, err := a.Redo()
+			if err != nil {
+				a.beep(1)
 
-		case 'D':
-			cardsTable.RemoveRow(row)
+				if errors.Is(err, errHistoryMismatch) {
+					showMessage(fmt.Sprintf("Could not redo %s, %v. The history has been cleared.", op.Kind, err))
+				}
+
+				return nil
+			}
+
+			if len(op.Changes) > 0 {
+				cardsTable.Select(op.Changes[0].Index+1, 0)
+			}
+			return nil
+		case 'E':
+			if row < 1 || row > len(a.selectedCards) {
+				return nil
+			}
+
+			editForm := a.editCardForm(row-1, func(index int) {
+				pages.RemovePage(editCardPageName)
+				tviewApp.SetFocus(cardsTable)
+				cardsTable.Select(index+1, 0)
+			})
+
+			pages.AddPage(editCardPageName, a.Modal(editForm, 5, 5), true, true)
+			tviewApp.SetFocus(editForm)
 			return nil
 		}
 
@@ -150,7 +273,7 @@ func (a *app) start() error {
 	tableFrame := tview.NewFrame(cardsTable)
 	tableFrame.SetBorders(0, 0, 0, 1, 0, 0)
 	tableFrame.SetBorder(true).SetTitle("Selected Cards")
-	tableFrame.AddText("+: Increment Quantity - -: Decrement Quantity - D: Delete Row", false, tview.AlignCenter, tcell.ColorYellow)
+	tableFrame.AddText("+: Increment Quantity - -: Decrement Quantity - D: Delete Row - E: Edit Details - u: Undo - r: Redo", false, tview.AlignCenter, tcell.ColorYellow)
 
 	/*
 		Card Input
@@ -170,13 +293,13 @@ func (a *app) start() error {
 			if len(matches) == 4 {
 				cardSet := matches[1]
 				cardNum := matches[2]
-				foil := matches[3] == "f"
+				finish, _ := deckbox.FinishFromSuffix(matches[3])
 
 				index, err := a.AddCard(cardMatch{
 					count:   1,
 					cardSet: cardSet,
 					cardNum: cardNum,
-					foil:    foil,
+					finish:  finish,
 				})
 
 				if err != nil {
@@ -235,68 +358,313 @@ func (a *app) start() error {
 
 	importFrame := tview.NewFrame(importFlex).
 		SetBorders(0, 0, 0, 1, 0, 0).
-		AddText("P: Paste - I: Import Moxfield - X: Cancel", false, tview.AlignCenter, tcell.ColorYellow)
+		AddText("P: Paste - I: Import - X: Cancel", false, tview.AlignCenter, tcell.ColorYellow)
 
 	importFrame.SetBorder(true).SetTitle("Import")
 
-	importFlex.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		switch event.Rune() {
-		case 'P':
-			importField.SetText(string(clipboard.Read(clipboard.FmtText)), true)
-			return nil
-		case 'I':
-			lines := strings.Split(strings.ReplaceAll(importField.GetText(), "\r\n", "\n"), "\n")
+	/*
+		Import Results Modal
+	*/
 
-			cardsToAdd := make([]cardMatch, 0, len(lines))
+	var importParser importer.Parser
+	var importResults []importer.Result
 
-			for i, l := range lines {
-				match := moxfieldImportRegexEval.FindStringSubmatch(l)
+	// Choices made in the results, keyed by line so they survive the import being re-run
+	importPicks := make(map[int]scryfall.Card)
+	importSkips := make(map[int]bool)
 
-				if len(match) == 5 || len(match) == 6 {
-					count, err := strconv.Atoi(match[1])
-					if err != nil {
-						a.beep(1)
-						errIdx := mapTextAreaCoord(importField, i, 0)
-						importField.Select(errIdx, errIdx)
-						return nil
-					}
-					// match[2] is card name which we don't need/want
-					cardSet := match[3]
-					cardNumber := match[4]
-					foil := false
+	importResultsTable := tview.NewTable()
+	importResultsTable.SetSelectable(true, false)
+	importResultsTable.SetFixed(1, 0)
 
-					if len(match) == 6 {
-						foil = len(match[5]) > 0
-					}
+	importResultsFrame := tview.NewFrame(importResultsTable).
+		SetBorders(0, 0, 0, 1, 0, 0).
+		AddText("A: Add Accepted - Enter: Pick Printing / Fix Line - K: Skip Line - S: Change Strategy - F: Change Format - X: Back", false, tview.AlignCenter, tcell.ColorYellow)
+
+	importResultsFrame.SetBorder(true)
+
+	renderImportResults := func() {
+		accepted, ambiguous, failed, skipped := 0, 0, 0, 0
+		for _, res := range importResults {
+			switch {
+			case res.Skipped:
+			case importSkips[res.Line]:
+				skipped++
+			case res.Resolved():
+				accepted++
+			case res.Ambiguous():
+				ambiguous++
+			case res.Err != nil:
+				failed++
+			}
+		}
 
-					cardsToAdd = append(cardsToAdd, cardMatch{
-						count:   count,
-						cardSet: cardSet,
-						cardNum: cardNumber,
-						foil:    foil,
-					})
-				} else {
-					a.beep(1)
-					errIdx := mapTextAreaCoord(importField, i, 0)
-					importField.Select(errIdx, errIdx)
-					return nil
+		importResultsFrame.SetTitle(fmt.Sprintf(
+			"Import Preview - %s - %d accepted, %d to pick, %d failed, %d skipped - Strategy: %s",
+			importParser.Name(), accepted, ambiguous, failed, skipped, a.names.Strategy,
+		))
+
+		importResultsTable.Clear()
+
+		for col, header := range []string{"Line", "Status", "Card", "Price", "Text"} {
+			importResultsTable.SetCell(0, col, tview.NewTableCell(header).SetSelectable(false).SetTextColor(tcell.ColorYellow))
+		}
+
+		for i, res := range importResults {
+			status, color, detail, price := "", tcell.ColorGray, "", ""
+
+			switch {
+			case res.Resolved():
+				status, color = "ok", tcell.ColorGreen
+				detail = a.importedCardDescription(*res.Card)
+				price = a.importedCardPrice(*res.Card)
+			case res.Ambiguous():
+				status, color = "pick", tcell.ColorYellow
+				detail = res.Err.Error()
+			case res.Err != nil:
+				status, color = "failed", tcell.ColorRed
+				detail = res.Err.Error()
+			}
+
+			if res.Skipped || importSkips[res.Line] {
+				status, color = "skip", tcell.ColorGray
+			}
+
+			importResultsTable.SetCell(i+1, 0, tview.NewTableCell(fmt.Sprint(res.Line)).SetTextColor(color))
+			importResultsTable.SetCell(i+1, 1, tview.NewTableCell(status).SetTextColor(color))
+			importResultsTable.SetCell(i+1, 2, tview.NewTableCell(detail).SetTextColor(color))
+			importResultsTable.SetCell(i+1, 3, tview.NewTableCell(price).SetTextColor(color).SetAlign(tview.AlignRight))
+			importResultsTable.SetCell(i+1, 4, tview.NewTableCell(res.Text).SetMaxWidth(40))
+		}
+	}
+
+	runImport := func(parser importer.Parser) {
+		importParser = parser
+		importResults = parser.Parse(importField.GetText(), importer.Context{
+			Store:    a.store,
+			Editions: a.editions,
+			Names:    a.names,
+		})
+
+		for i, res := range importResults {
+			for _, card := range res.Candidates {
+				if picked, ok := importPicks[res.Line]; ok && picked.Id == card.Id {
+					importResults[i].Pick(card)
 				}
 			}
+		}
+
+		for _, res := range importResults {
+			if res.Err != nil && !importSkips[res.Line] {
+				a.beep(1)
+				break
+			}
+		}
 
-			for _, cm := range cardsToAdd {
-				_, err := a.AddCard(cm)
-				if err != nil {
-					fmt.Print(err)
-					a.beep(1)
-					break
+		renderImportResults()
+	}
+
+	/*
+		Import Printing Picker
+	*/
+
+	importPickerList := tview.NewList().ShowSecondaryText(false)
+	importPickerList.SetBorder(true)
+
+	importPickerList.SetDoneFunc(func() {
+		pages.HidePage(importPickerPageName)
+		tviewApp.SetFocus(importResultsTable)
+	})
+
+	showImportPicker := func(index int) {
+		res := importResults[index]
+
+		importPickerList.Clear()
+		importPickerList.SetTitle(fmt.Sprintf("%s - Pick a Printing (Esc: Cancel)", res.Entry.Name))
+
+		for _, card := range res.Candidates {
+			card := card
+
+			price := res.Entry.CardFinish().Price(card)
+			if price == "" {
+				price = "-"
+			}
+
+			label := fmt.Sprintf("%s (%s %s) %s - $%s", card.SetName, card.Set, card.CollectorNumber, card.ReleasedAt, price)
+
+			importPickerList.AddItem(label, "", 0, func() {
+				importPicks[res.Line] = card
+				importResults[index].Pick(card)
+				renderImportResults()
+
+				pages.HidePage(importPickerPageName)
+				tviewApp.SetFocus(importResultsTable)
+			})
+		}
+
+		pages.ShowPage(importPickerPageName)
+		tviewApp.SetFocus(importPickerList)
+	}
+
+	/*
+		Import Line Fixer
+	*/
+
+	importFixField := tview.NewInputField()
+	importFixField.SetBorder(true)
+
+	importFixLine := 0
+
+	importFixField.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			lines := strings.Split(strings.ReplaceAll(importField.GetText(), "\r\n", "\n"), "\n")
+			if importFixLine >= 1 && importFixLine <= len(lines) {
+				lines[importFixLine-1] = importFixField.GetText()
+				importField.SetText(strings.Join(lines, "\n"), false)
+
+				// The line has changed, so an earlier choice may no longer apply
+				delete(importPicks, importFixLine)
+
+				row, _ := importResultsTable.GetSelection()
+				runImport(importParser)
+				importResultsTable.Select(row, 0)
+			}
+		}
+
+		pages.HidePage(importFixPageName)
+		tviewApp.SetFocus(importResultsTable)
+	})
+
+	showImportFix := func(res importer.Result) {
+		importFixLine = res.Line
+		importFixField.SetTitle(fmt.Sprintf("Fix Line %d (Enter: Save - Esc: Cancel)", res.Line))
+		importFixField.SetText(res.Text)
+
+		pages.ShowPage(importFixPageName)
+		tviewApp.SetFocus(importFixField)
+	}
+
+	importResultsTable.SetSelectedFunc(func(row, column int) {
+		if row < 1 || row > len(importResults) {
+			return
+		}
+
+		res := importResults[row-1]
+
+		if len(res.Candidates) > 1 {
+			showImportPicker(row - 1)
+			return
+		}
+
+		showImportFix(res)
+	})
+
+	importResultsTable.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case 'A':
+			accepted := make([]deckbox.SelectedCard, 0, len(importResults))
+			for _, res := range importResults {
+				if res.Resolved() && !importSkips[res.Line] {
+					accepted = append(accepted, *res.Card)
 				}
 			}
 
+			if len(accepted) == 0 {
+				a.beep(1)
+				return nil
+			}
+
+			a.mergeSelectedCards(accepted)
+
+			importResults = nil
+			importPicks = make(map[int]scryfall.Card)
+			importSkips = make(map[int]bool)
 			importField.SetText("", true)
+			pages.HidePage(importResultsPageName)
 			pages.HidePage(importModalPageName)
 			tviewApp.SetFocus(cardsTable)
 			cardsTable.Select(len(a.selectedCards), 0)
 
+			return nil
+		case 'K':
+			row, _ := importResultsTable.GetSelection()
+			if row < 1 || row > len(importResults) || importResults[row-1].Skipped {
+				return nil
+			}
+
+			line := importResults[row-1].Line
+			importSkips[line] = !importSkips[line]
+			renderImportResults()
+
+			return nil
+		case 'S':
+			// Cycle through the strategies, re-resolving with the next one. Printings that were picked are kept.
+			i := 0
+			for i < len(importer.Strategies) && importer.Strategies[i] != a.names.Strategy {
+				i++
+			}
+
+			a.names.Strategy = importer.Strategies[(i+1)%len(importer.Strategies)]
+
+			row, _ := importResultsTable.GetSelection()
+			runImport(importParser)
+			importResultsTable.Select(row, 0)
+
+			return nil
+		case 'F':
+			// Cycle through the formats, for when the detected one is wrong
+			names := importer.Names()
+
+			i := 0
+			for i < len(names) && names[i] != importParser.Name() {
+				i++
+			}
+
+			parser, ok := importer.Get(names[(i+1)%len(names)])
+			if !ok {
+				return nil
+			}
+
+			row, _ := importResultsTable.GetSelection()
+			runImport(parser)
+			importResultsTable.Select(row, 0)
+
+			return nil
+		case 'X':
+			// Back to the text, at the selected line
+			row, _ := importResultsTable.GetSelection()
+			if row >= 1 && row <= len(importResults) {
+				idx := mapTextAreaCoord(importField, importResults[row-1].Line-1, 0)
+				importField.Select(idx, idx)
+			}
+
+			pages.HidePage(importResultsPageName)
+			tviewApp.SetFocus(importField)
+			return nil
+		default:
+			return event
+		}
+	})
+
+	importFlex.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case 'P':
+			importField.SetText(string(clipboard.Read(clipboard.FmtText)), true)
+			return nil
+		case 'I':
+			if strings.TrimSpace(importField.GetText()) == "" {
+				a.beep(1)
+				return nil
+			}
+
+			importPicks = make(map[int]scryfall.Card)
+			importSkips = make(map[int]bool)
+			runImport(importer.Detect(importField.GetText()))
+
+			importResultsTable.Select(1, 0).ScrollToBeginning()
+			pages.ShowPage(importResultsPageName)
+			tviewApp.SetFocus(importResultsTable)
+
 			return nil
 		case 'X':
 			pages.HidePage(importModalPageName)
@@ -307,6 +675,9 @@ func (a *app) start() error {
 	})
 
 	importModal := a.Modal(importFrame, 5, 5)
+	importResultsModal := a.Modal(importResultsFrame, 3, 3)
+	importPickerModal := a.Modal(importPickerList, 10, 5)
+	importFixModal := a.Modal(importFixField, 1, 10)
 
 	/*
 		QuickSearch Modal
@@ -314,25 +685,206 @@ func (a *app) start() error {
 
 	quickSearchTableComponent := tview.NewTable()
 	quickSearchTableComponent.SetContent(&quickSearchTable{app: a})
+	quickSearchTableComponent.SetSelectable(true, false)
+	quickSearchTableComponent.SetFixed(1, 0)
 
 	quickSearchField := tview.NewInputField()
-	quickSearchField.SetLabel("Card Name: ")
-
-	quickSearchField.SetChangedFunc(func(text string) {
-		a.quickSearchCardsList = a.store.Index.Search(text)
-	})
+	quickSearchField.SetLabel("Card Name or Query: ")
 
 	quickSearchFlex := tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(quickSearchField, 0, 1, true).
 		AddItem(quickSearchTableComponent, 0, 10, false)
 
+	quickSearchFrame := tview.NewFrame(quickSearchFlex).
+		SetBorders(0, 0, 0, 1, 0, 0).
+		AddText("C: Clear - X: Close - Tab: Results - Enter: Add - F: Add Foil - E: Add Etched - 0-9: Quantity", false, tview.AlignCenter, tcell.ColorYellow).
+		AddText("Include - Ctrl-T: Tokens - Ctrl-R: Art Series - Ctrl-D: Digital (Not Legal) - Ctrl-L: Not Legal (Un-sets, Promos...)", false, tview.AlignCenter, tcell.ColorYellow)
+	quickSearchFrame.SetBorder(true).SetTitle("Quick Search")
+
+	// Digits typed in the results before adding, e.g. "4" then Enter adds 4 copies
+	quickSearchCount := ""
+
+	setQuickSearchTitle := func(status string) {
+		title := "Quick Search"
+
+		if a.searchInclude != data.IncludeNone {
+			title += fmt.Sprintf(" - Including: %s", a.searchInclude)
+		}
+		if quickSearchCount != "" {
+			title += fmt.Sprintf(" - Quantity: %s", quickSearchCount)
+		}
+		if status != "" {
+			title += " - " + status
+		}
+
+		quickSearchFrame.SetTitle(title)
+	}
+
+	// Bumped by every search, so a query still running when the text changes doesn't replace the newer results
+	quickSearchGeneration := 0
+
+	runQuickSearch := func(text string) {
+		// Scryfall style queries search every card, anything else is a name. Names can look like queries
+		// (e.g. "Circle of Protection: Red"), so if it doesn't parse it's searched as a name as well.
+		status := ""
+
+		quickSearchGeneration++
+		generation := quickSearchGeneration
+
+		if query.LooksLikeQuery(text) {
+			q, err := query.Parse(text)
+			if err == nil {
+				include := a.searchInclude
+
+				setQuickSearchTitle("Searching...")
+
+				// Queries scan every card, so they're run off the UI goroutine to keep typing responsive
+				go func() {
+					cards := make([]scryfall.Card, 0)
+
+					for _, card := range query.Search(a.store, q) {
+						if data.CardExclusions(card).Allows(include) {
+							cards = append(cards, card)
+						}
+					}
+
+					tviewApp.QueueUpdateDraw(func() {
+						if generation != quickSearchGeneration {
+							return
+						}
+
+						a.quickSearchCardsList = cards
+						setQuickSearchTitle(fmt.Sprintf("Query: %d cards", len(cards)))
+						quickSearchTableComponent.Select(1, 0).ScrollToBeginning()
+					})
+				}()
+
+				return
+			}
+
+			status = fmt.Sprintf("Not a query, %v", err)
+		}
+
+		a.quickSearchCardsList = a.store.Index.Search(text, a.searchInclude)
+		setQuickSearchTitle(status)
+		quickSearchTableComponent.Select(1, 0).ScrollToBeginning()
+	}
+
+	quickSearchField.SetChangedFunc(runQuickSearch)
+
+	quickSearchAdd := func(finish deckbox.Finish) {
+		row, _ := quickSearchTableComponent.GetSelection()
+		if row < 1 || row > len(a.quickSearchCardsList) {
+			a.beep(1)
+			return
+		}
+
+		card := a.quickSearchCardsList[row-1]
+
+		count := 1
+		if quickSearchCount != "" {
+			count, _ = strconv.Atoi(quickSearchCount)
+		}
+
+		quickSearchCount = ""
+
+		if count < 1 {
+			a.beep(1)
+			setQuickSearchTitle("Invalid quantity")
+			return
+		}
+
+		index, err := a.AddCard(cardMatch{
+			count:   count,
+			cardSet: card.Set,
+			cardNum: card.CollectorNumber,
+			finish:  finish,
+		})
+		if errors.Is(err, errFinishUnavailable) {
+			setQuickSearchTitle(fmt.Sprintf("No %s printing", finish))
+			return
+		} else if err != nil {
+			setQuickSearchTitle(fmt.Sprintf("Could not add %s (%s %s), it's missing from the card data", deckbox.CardName(card), card.Set, card.CollectorNumber))
+			return
+		}
+
+		cardsTable.Select(index+1, 0)
+		setQuickSearchTitle(fmt.Sprintf("Added %d %s (%s %s) %s", count, deckbox.CardName(card), card.Set, card.CollectorNumber, finish))
+	}
+
+	quickSearchField.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter, tcell.KeyTab, tcell.KeyDown:
+			if len(a.quickSearchCardsList) > 0 {
+				quickSearchTableComponent.Select(1, 0)
+				tviewApp.SetFocus(quickSearchTableComponent)
+			}
+		}
+	})
+
+	quickSearchTableComponent.SetSelectedFunc(func(row, column int) {
+		quickSearchAdd(deckbox.FinishNonfoil)
+	})
+
+	quickSearchTableComponent.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyTab, tcell.KeyBacktab:
+			quickSearchCount = ""
+			setQuickSearchTitle("")
+			tviewApp.SetFocus(quickSearchField)
+			return nil
+		case tcell.KeyEscape, tcell.KeyBackspace, tcell.KeyBackspace2:
+			quickSearchCount = ""
+			setQuickSearchTitle("")
+			return nil
+		}
+
+		switch r := event.Rune(); {
+		case r >= '0' && r <= '9':
+			quickSearchCount += string(r)
+			setQuickSearchTitle("")
+			return nil
+		case r == 'F':
+			quickSearchAdd(deckbox.FinishFoil)
+			return nil
+		case r == 'E':
+			quickSearchAdd(deckbox.FinishEtched)
+			return nil
+		default:
+			return event
+		}
+	})
+
 	quickSearchFlex.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		// Toggle the cards usually left out of searches
+		var toggle data.Exclusions
+
+		switch event.Key() {
+		case tcell.KeyCtrlT:
+			toggle = data.ExcludedToken
+		case tcell.KeyCtrlR:
+			toggle = data.ExcludedArtSeries
+		case tcell.KeyCtrlD:
+			toggle = data.ExcludedDigital
+		case tcell.KeyCtrlL:
+			toggle = data.ExcludedNotLegal
+		}
+
+		if toggle != 0 {
+			a.searchInclude ^= toggle
+			runQuickSearch(quickSearchField.GetText())
+			return nil
+		}
+
 		switch event.Rune() {
 		case 'C':
 			quickSearchField.SetText("")
+			tviewApp.SetFocus(quickSearchField)
 			return nil
 		case 'X':
 			quickSearchField.SetText("")
+			quickSearchCount = ""
+			setQuickSearchTitle("")
 			pages.HidePage(quickSearchPageName)
 			return nil
 		default:
@@ -340,13 +892,86 @@ func (a *app) start() error {
 		}
 	})
 
-	quickSearchFrame := tview.NewFrame(quickSearchFlex).
-		SetBorders(0, 0, 0, 1, 0, 0).
-		AddText("C: Clear - X: Close", false, tview.AlignCenter, tcell.ColorYellow)
-	quickSearchFrame.SetBorder(true).SetTitle("Quick Search")
-
 	quickSearchModal := a.Modal(quickSearchFrame, 5, 5)
 
+	// Shown when the only thing stopping a Deckbox export is editions that may not match
+	var showUnmapped func()
+
+	doExport := func(allowUnmapped bool) {
+		exporter := a.exporter
+		if de, isDeckbox := exporter.(deckbox.Exporter); isDeckbox && allowUnmapped {
+			de.AllowUnmapped = true
+			exporter = de
+		}
+
+		_, err := export.WriteFile(a.filepath, exporter, a.selectedCards, a.store)
+		if err != nil {
+			if deckbox.OnlyUnmappedEditions(err) {
+				showUnmapped()
+				return
+			}
+
+			a.beep(1)
+			showMessage(exportErrorMessage(err))
+			return
+		}
+
+		a.beep(2)
+	}
+
+	/*
+		Unmapped Editions Modal
+	*/
+
+	unmappedModal := tview.NewModal().
+		AddButtons([]string{"Export Anyway", "Cancel"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			pages.HidePage(unmappedModalPageName)
+			tviewApp.SetFocus(cardsTable)
+
+			if buttonLabel == "Export Anyway" {
+				doExport(true)
+			}
+		})
+
+	showUnmapped = func() {
+		unmappedModal.SetText(fmt.Sprintf(
+			"These editions may not match Deckbox, add them to %s if the import fails:\n\n%s",
+			deckbox.EditionOverrideFileName,
+			strings.Join(a.editions.UnmappedEditions(a.selectedCards, a.store), "\n"),
+		))
+		pages.ShowPage(unmappedModalPageName)
+		tviewApp.SetFocus(unmappedModal)
+	}
+
+	/*
+		Export Format Modal
+	*/
+
+	exportFormatList := tview.NewList().ShowSecondaryText(false)
+
+	for _, name := range export.Names() {
+		exporter, _ := export.Get(name)
+
+		exportFormatList.AddItem(fmt.Sprintf("%s (.%s)", name, exporter.Extension()), "", 0, func() {
+			a.exporter = exporter
+
+			pages.HidePage(exportFormatPageName)
+			tviewApp.SetFocus(cardsTable)
+
+			doExport(false)
+		})
+	}
+
+	exportFormatList.SetDoneFunc(func() {
+		pages.HidePage(exportFormatPageName)
+		tviewApp.SetFocus(cardsTable)
+	})
+
+	exportFormatList.SetBorder(true).SetTitle("Export Format (Esc: Cancel)")
+
+	exportFormatModal := a.Modal(exportFormatList, 2, 2)
+
 	/*
 		Main page
 	*/
@@ -379,11 +1004,14 @@ func (a *app) start() error {
 			tviewApp.SetFocus(cardsTable)
 			return nil
 		case 'X':
-			err := deckbox.Export(a.filepath, a.selectedCards, a.store)
-			if err != nil {
-				panic(err)
+			for i, name := range export.Names() {
+				if name == a.exporter.Name() {
+					exportFormatList.SetCurrentItem(i)
+				}
 			}
-			a.beep(2)
+
+			pages.ShowPage(exportFormatPageName)
+			tviewApp.SetFocus(exportFormatList)
 			return nil
 		case 'I':
 			pages.ShowPage(importModalPageName)
@@ -404,38 +1032,93 @@ func (a *app) start() error {
 
 	pages.AddPage(mainPageName, mainFrame, true, true)
 	pages.AddPage(importModalPageName, importModal, true, false)
+	pages.AddPage(importResultsPageName, importResultsModal, true, false)
+	pages.AddPage(importPickerPageName, importPickerModal, true, false)
+	pages.AddPage(importFixPageName, importFixModal, true, false)
 	pages.AddPage(quickSearchPageName, quickSearchModal, true, false)
+	pages.AddPage(unmappedModalPageName, unmappedModal, true, false)
+	pages.AddPage(exportFormatPageName, exportFormatModal, true, false)
+	pages.AddPage(messageModalPageName, messageModal, true, false)
+
+	/*
+		Autosave
+	*/
+
+	go func() {
+		// A failure is only shown once, until a save succeeds
+		lastErr := ""
+
+		for range a.autosaveTimer.C {
+			var cards, hist []byte
+			var err error
+
+			encoded := make(chan struct{})
+			tviewApp.QueueUpdate(func() {
+				defer close(encoded)
+				cards, hist, err = a.encodeSession()
+			})
+			<-encoded
+
+			if err == nil {
+				err = a.writeSession(cards, hist)
+			}
+
+			if err == nil {
+				lastErr = ""
+			} else if err.Error() != lastErr {
+				lastErr = err.Error()
+
+				tviewApp.QueueUpdateDraw(func() {
+					a.beep(1)
+					showMessage(fmt.Sprintf("Autosave failed, your changes have not been saved: %v", err))
+				})
+			}
+		}
+	}()
 
 	return tviewApp.SetRoot(pages, true).Run()
 }
 
-func (a *app) AddCard(cm cardMatch) (int, error) {
-	// Trim leading zeroes from the card number
-	cardNum := strings.TrimLeft(cm.cardNum, "0")
+/*
+exportErrorMessage describes a failed export for the message modal, listing the offending rows if known
+*/
+func exportErrorMessage(err error) string {
+	var exportErr deckbox.ExportError
+	if !errors.As(err, &exportErr) {
+		return fmt.Sprintf("Export failed, nothing was written:\n\n%v", err)
+	}
+
+	lines := make([]string, 0, len(exportErr.Rows))
 
+	for _, re := range exportErr.Rows {
+		lines = append(lines, fmt.Sprintf("Row %d (%s %s): %v", re.Row+1, re.Card.Set, re.Card.Number, re.Err))
+	}
+
+	return fmt.Sprintf("Export failed, nothing was written. Fix or delete these rows:\n\n%s", strings.Join(lines, "\n"))
+}
+
+func (a *app) AddCard(cm cardMatch) (int, error) {
 	// Prefer the set code from the card input
 	selectedSet := a.selectedSet
 	if cm.cardSet != "" {
 		selectedSet = strings.ToLower(cm.cardSet)
 	}
 
-	card, ok := a.store.SetCards[selectedSet][cardNum]
+	// Leading zeroes typed in the card input are tolerated
+	card, ok := deckbox.LookupNumber(a.store.SetCards[selectedSet], cm.cardNum)
 	if !ok {
 		a.beep(1)
-		return 0, fmt.Errorf("SetCards did not contain %s - %s", selectedSet, cardNum)
+		return 0, fmt.Errorf("%w: SetCards did not contain %s - %s", errNoPrinting, selectedSet, cm.cardNum)
 	}
 
-	if cm.foil && !card.Foil || !cm.foil && !card.Nonfoil {
+	cardNum := card.CollectorNumber
+
+	if !cm.finish.AvailableFor(card) {
 		a.beep(1)
-		return 0, fmt.Errorf("foil value '%v' is not valid for %s - %s", cm.foil, selectedSet, cardNum)
+		return 0, fmt.Errorf("%w: finish '%s' is not valid for %s - %s", errFinishUnavailable, cm.finish, selectedSet, cardNum)
 	}
 
-	var price string
-	if cm.foil {
-		price = card.Prices.UsdFoil
-	} else {
-		price = card.Prices.Usd
-	}
+	price := cm.finish.Price(card)
 
 	// Notify if price above threshold
 	priceF, err := strconv.ParseFloat(price, 64)
@@ -447,33 +1130,227 @@ func (a *app) AddCard(cm cardMatch) (int, error) {
 		}
 	}
 
-	// Find if card has already been added
-	index := -1
 	sCard := deckbox.SelectedCard{
 		Set:      selectedSet,
-		Quantity: 0,
+		Quantity: cm.count,
 		Number:   cardNum,
-		Foil:     cm.foil,
+		Finish:   cm.finish,
+	}
+
+	return a.mergeSelectedCard(sCard), nil
+}
+
+/*
+mergeSelectedCard adds the card to the selected cards, combining it with an existing row for the same card if
+there is one. It returns the index of the row.
+*/
+func (a *app) mergeSelectedCard(sCard deckbox.SelectedCard) int {
+	change := mergeChange(a.selectedCards, sCard)
+
+	err := a.do(operation{Kind: opAdd, Changes: []rowChange{change}})
+	if err != nil {
+		a.beep(1)
+	}
+
+	return change.Index
+}
+
+/*
+mergeSelectedCards adds several cards at once as a single operation, e.g. an import. They're merged into a copy of
+the selected cards which then replaces them in one step, so the autosave never writes half of them.
+*/
+func (a *app) mergeSelectedCards(sCards []deckbox.SelectedCard) {
+	op := operation{Kind: opImport, Changes: make([]rowChange, 0, len(sCards))}
+
+	merged := make([]deckbox.SelectedCard, len(a.selectedCards), len(a.selectedCards)+len(sCards))
+	copy(merged, a.selectedCards)
+
+	for _, sCard := range sCards {
+		change := mergeChange(merged, sCard)
+		merged, _ = change.apply(merged)
+
+		op.Changes = append(op.Changes, change)
+	}
+
+	err := a.do(op)
+	if err != nil {
+		a.beep(1)
+	}
+}
+
+/*
+ChangeQuantity adds delta to the quantity of the selected card at index, removing the row if none are left
+*/
+func (a *app) ChangeQuantity(index int, delta int) {
+	before := a.selectedCards[index]
+
+	after := before
+	after.Quantity += delta
+
+	if after.TradelistCount > after.Quantity {
+		after.TradelistCount = after.Quantity
+	}
+
+	change := rowChange{Index: index, Before: &before, After: &after}
+	kind := opIncrement
+
+	if delta < 0 {
+		kind = opDecrement
+	}
+
+	if after.Quantity < 1 {
+		change.After = nil
+	}
+
+	err := a.do(operation{Kind: kind, Changes: []rowChange{change}})
+	if err != nil {
+		a.beep(1)
+	}
+}
+
+/*
+DeleteCard removes the selected card at index
+*/
+func (a *app) DeleteCard(index int) {
+	before := a.selectedCards[index]
+
+	err := a.do(operation{Kind: opDelete, Changes: []rowChange{{Index: index, Before: &before}}})
+	if err != nil {
+		a.beep(1)
+	}
+}
+
+/*
+importedCardDescription describes an imported card for the import results, e.g. "Lightning Bolt (m10 146) x4 foil"
+*/
+func (a *app) importedCardDescription(sCard deckbox.SelectedCard) string {
+	name := sCard.Number
+	if card, ok := a.store.SetCards[sCard.Set][sCard.Number]; ok {
+		name = deckbox.CardName(card)
+	}
+
+	desc := fmt.Sprintf("%s (%s %s) x%d", name, sCard.Set, sCard.Number, sCard.Quantity)
+	if sCard.Finish != deckbox.FinishNonfoil {
+		desc += " " + string(sCard.Finish)
 	}
 
+	return desc
+}
+
+/*
+importedCardPrice is the USD price of an imported card in its finish, e.g. "$1.50"
+*/
+func (a *app) importedCardPrice(sCard deckbox.SelectedCard) string {
+	card, ok := a.store.SetCards[sCard.Set][sCard.Number]
+	if !ok {
+		return ""
+	}
+
+	price := sCard.Finish.Price(card)
+	if price == "" {
+		return "-"
+	}
+
+	return "$" + price
+}
+
+/*
+editCardForm builds a form for the Deckbox attributes of the selected card at index.
+done is called with the card's index once the form is closed, which can change if it was merged into another row.
+*/
+func (a *app) editCardForm(index int, done func(index int)) *tview.Form {
+	sCard := a.selectedCards[index]
+
+	form := tview.NewForm()
+
+	tradelistCount := fmt.Sprint(sCard.TradelistCount)
+	form.AddInputField("Tradelist Count", tradelistCount, 10, tview.InputFieldInteger, func(text string) {
+		tradelistCount = text
+	})
+
+	conditions := append([]string{""}, deckbox.Conditions...)
+	form.AddDropDown("Condition", conditions, indexOf(conditions, sCard.Condition), func(option string, _ int) {
+		sCard.Condition = option
+	})
+
+	languages := append([]string{""}, deckbox.Languages...)
+	form.AddDropDown("Language", languages, indexOf(languages, sCard.Language), func(option string, _ int) {
+		sCard.Language = option
+	})
+
+	form.AddCheckbox("Signed", sCard.Signed, func(checked bool) { sCard.Signed = checked })
+	form.AddCheckbox("Artist Proof", sCard.ArtistProof, func(checked bool) { sCard.ArtistProof = checked })
+	form.AddCheckbox("Altered Art", sCard.AlteredArt, func(checked bool) { sCard.AlteredArt = checked })
+	form.AddCheckbox("Misprint", sCard.Misprint, func(checked bool) { sCard.Misprint = checked })
+	form.AddCheckbox("Promo", sCard.Promo, func(checked bool) { sCard.Promo = checked })
+	form.AddCheckbox("Textless", sCard.Textless, func(checked bool) { sCard.Textless = checked })
+
+	form.AddInputField("My Price", sCard.MyPrice, 10, nil, func(text string) {
+		sCard.MyPrice = text
+	})
+
+	form.AddButton("Save", func() {
+		count, err := strconv.Atoi(tradelistCount)
+		if tradelistCount == "" {
+			count, err = 0, nil
+		}
+
+		if err != nil || count < 0 || count > sCard.Quantity {
+			a.beep(1)
+			return
+		}
+
+		sCard.TradelistCount = count
+
+		done(a.UpdateCard(index, sCard))
+	})
+
+	form.AddButton("Cancel", func() {
+		done(index)
+	})
+
+	form.SetBorder(true).SetTitle("Edit Details")
+
+	return form
+}
+
+/*
+UpdateCard replaces the selected card at index, merging it into another row if it is now the same card as that row.
+It returns the index the card ended up at.
+*/
+func (a *app) UpdateCard(index int, sCard deckbox.SelectedCard) int {
+	before := a.selectedCards[index]
+
+	op := operation{Kind: opEdit, Changes: []rowChange{{Index: index, Before: &before, After: &sCard}}}
+	newIndex := index
+
 	for i, c := range a.selectedCards {
-		if c.Set == selectedSet && c.Number == cardNum && c.Foil == cm.foil {
-			sCard = c
-			index = i
+		if i != index && c.SameCard(sCard) {
+			merged := c
+			merged.Quantity += sCard.Quantity
+			merged.TradelistCount += sCard.TradelistCount
+
+			op.Changes = []rowChange{
+				{Index: i, Before: &c, After: &merged},
+				{Index: index, Before: &before},
+			}
+
+			newIndex = i
+			if i > index {
+				newIndex = i - 1
+			}
+
 			break
 		}
 	}
 
-	sCard.Quantity += cm.count
-
-	if index == -1 {
-		a.selectedCards = append(a.selectedCards, sCard)
-		index = len(a.selectedCards) - 1
-	} else {
-		a.selectedCards[index] = sCard
+	err := a.do(op)
+	if err != nil {
+		a.beep(1)
+		return index
 	}
 
-	return index, nil
+	return newIndex
 }
 
 /*
@@ -516,6 +1393,19 @@ func mapTextAreaCoord(ta *tview.TextArea, row, col int) int {
 	return count + col
 }
 
+/*
+Util method to find the index of a string in a list, or 0 if it's not there
+*/
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+
+	return 0
+}
+
 type quickSearchTable struct {
 	app *app
 }
@@ -524,15 +1414,17 @@ func (qst *quickSearchTable) GetCell(row, column int) *tview.TableCell {
 	if row == 0 { // Header row
 		switch column {
 		case 0:
-			return tview.NewTableCell("Name").SetTextColor(tcell.ColorYellow)
+			return tview.NewTableCell("Name").SetTextColor(tcell.ColorYellow).SetSelectable(false)
 		case 1:
-			return tview.NewTableCell("Set").SetTextColor(tcell.ColorYellow)
+			return tview.NewTableCell("Set").SetTextColor(tcell.ColorYellow).SetSelectable(false)
 		case 2:
-			return tview.NewTableCell("Number").SetTextColor(tcell.ColorYellow)
+			return tview.NewTableCell("Number").SetTextColor(tcell.ColorYellow).SetSelectable(false)
 		case 3:
-			return tview.NewTableCell("Price").SetTextColor(tcell.ColorYellow)
+			return tview.NewTableCell("Price").SetTextColor(tcell.ColorYellow).SetSelectable(false)
 		case 4:
-			return tview.NewTableCell("Foil Price").SetTextColor(tcell.ColorYellow)
+			return tview.NewTableCell("Foil Price").SetTextColor(tcell.ColorYellow).SetSelectable(false)
+		case 5:
+			return tview.NewTableCell("Etched Price").SetTextColor(tcell.ColorYellow).SetSelectable(false)
 		default:
 			return nil
 		}
@@ -541,33 +1433,23 @@ func (qst *quickSearchTable) GetCell(row, column int) *tview.TableCell {
 
 		price := ""
 		foilPrice := ""
+		etchedPrice := ""
 
 		color := tcell.ColorWhite
 		foilColor := tcell.ColorWhite
+		etchedColor := tcell.ColorWhite
 
 		if card.Nonfoil {
 			price = card.Prices.Usd
-
-			priceF, err := strconv.ParseFloat(price, 64)
-			if err == nil {
-				if priceF > 10 {
-					color = tcell.ColorRed
-				} else if priceF > 2.5 {
-					color = tcell.ColorOrange
-				}
-			}
+			color = priceColor(price)
 		}
 		if card.Foil {
 			foilPrice = card.Prices.UsdFoil
-
-			priceF, err := strconv.ParseFloat(foilPrice, 64)
-			if err == nil {
-				if priceF > 10 {
-					foilColor = tcell.ColorRed
-				} else if priceF > 2.5 {
-					foilColor = tcell.ColorOrange
-				}
-			}
+			foilColor = priceColor(foilPrice)
+		}
+		if deckbox.FinishEtched.AvailableFor(card) {
+			etchedPrice = card.Prices.UsdEtched
+			etchedColor = priceColor(etchedPrice)
 		}
 
 		switch column {
@@ -581,6 +1463,8 @@ func (qst *quickSearchTable) GetCell(row, column int) *tview.TableCell {
 			return tview.NewTableCell(price).SetTextColor(color)
 		case 4:
 			return tview.NewTableCell(foilPrice).SetTextColor(foilColor)
+		case 5:
+			return tview.NewTableCell(etchedPrice).SetTextColor(etchedColor)
 		}
 
 		return tview.NewTableCell("")
@@ -599,8 +1483,9 @@ func (qst *quickSearchTable) GetColumnCount() int {
 		- Collector Number
 		- Price
 		- Foil Price
+		- Etched Price
 	*/
-	return 5
+	return 6
 }
 
 func (qst *quickSearchTable) SetCell(row, column int, cell *tview.TableCell) {
@@ -643,9 +1528,11 @@ func (sct *selectedCardTable) GetCell(row, column int) *tview.TableCell {
 		case 3:
 			return tview.NewTableCell("Name").SetTextColor(tcell.ColorYellow)
 		case 4:
-			return tview.NewTableCell("Foil").SetTextColor(tcell.ColorYellow)
+			return tview.NewTableCell("Finish").SetTextColor(tcell.ColorYellow)
 		case 5:
 			return tview.NewTableCell("Price").SetTextColor(tcell.ColorYellow)
+		case 6:
+			return tview.NewTableCell("Details").SetTextColor(tcell.ColorYellow)
 		default:
 			return nil
 		}
@@ -653,24 +1540,9 @@ func (sct *selectedCardTable) GetCell(row, column int) *tview.TableCell {
 		sCard := sct.app.selectedCards[row-1]
 		card := sct.app.store.SetCards[sCard.Set][sCard.Number]
 
-		price := ""
+		price := sCard.Finish.Price(card)
 
-		if sCard.Foil {
-			price = card.Prices.UsdFoil
-		} else {
-			price = card.Prices.Usd
-		} // TODO: Etched price?
-
-		color := tcell.ColorWhite
-
-		priceF, err := strconv.ParseFloat(price, 64)
-		if err == nil {
-			if priceF > 10 {
-				color = tcell.ColorRed
-			} else if priceF > 2.5 {
-				color = tcell.ColorOrange
-			}
-		}
+		color := priceColor(price)
 
 		switch column {
 		case 0:
@@ -682,10 +1554,12 @@ func (sct *selectedCardTable) GetCell(row, column int) *tview.TableCell {
 		case 3:
 			return tview.NewTableCell(card.Name).SetTextColor(color)
 		case 4:
-			return tview.NewTableCell(fmt.Sprint(sCard.Foil)).SetTextColor(color)
+			return tview.NewTableCell(string(sCard.Finish)).SetTextColor(color)
 		case 5:
 
 			return tview.NewTableCell(price).SetTextColor(color)
+		case 6:
+			return tview.NewTableCell(cardDetails(sCard)).SetTextColor(color)
 		default:
 			return nil
 		}
@@ -703,10 +1577,68 @@ func (sct *selectedCardTable) GetColumnCount() int {
 		* Set Code - string
 		* Card Number - string
 		* Card Name - string
-		* Foil - bool
+		* Finish - string
 		* Price - int
+		* Details - string
 	*/
-	return 6
+	return 7
+}
+
+/*
+priceColor highlights prices above the notification thresholds, orange over $2.50 and red over $10
+*/
+func priceColor(price string) tcell.Color {
+	priceF, err := strconv.ParseFloat(price, 64)
+	if err == nil {
+		if priceF > 10 {
+			return tcell.ColorRed
+		} else if priceF > 2.5 {
+			return tcell.ColorOrange
+		}
+	}
+
+	return tcell.ColorWhite
+}
+
+/*
+cardDetails summarises the Deckbox attributes that have been set on a card
+*/
+func cardDetails(sCard deckbox.SelectedCard) string {
+	details := make([]string, 0)
+
+	if sCard.TradelistCount > 0 {
+		details = append(details, fmt.Sprintf("%d trade", sCard.TradelistCount))
+	}
+
+	for _, d := range []string{sCard.Condition, sCard.Language} {
+		if d != "" {
+			details = append(details, d)
+		}
+	}
+
+	if sCard.MyPrice != "" {
+		details = append(details, "my price "+sCard.MyPrice)
+	}
+
+	flags := []struct {
+		set  bool
+		name string
+	}{
+		{sCard.Signed, "signed"},
+		{sCard.ArtistProof, "proof"},
+		{sCard.AlteredArt, "altered"},
+		{sCard.Misprint, "misprint"},
+		{sCard.Promo, "promo"},
+		{sCard.Textless, "textless"},
+	}
+
+	for _, f := range flags {
+		if f.set {
+			details = append(details, f.name)
+		}
+	}
+
+	return strings.Join(details, ", ")
 }
 
 func (sct *selectedCardTable) SetCell(row, column int, cell *tview.TableCell) {
@@ -714,8 +1646,8 @@ func (sct *selectedCardTable) SetCell(row, column int, cell *tview.TableCell) {
 }
 
 func (sct *selectedCardTable) RemoveRow(row int) {
-	if row > 0 { // Can't remove header
-		sct.app.selectedCards = append(sct.app.selectedCards[:row-1], sct.app.selectedCards[row:]...)
+	if row > 0 && row <= len(sct.app.selectedCards) { // Can't remove header
+		sct.app.DeleteCard(row - 1)
 	}
 }
 